@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Links known to be broken and accepted, which do not fail the run. Each line of a baseline file is a URL,
+// or a glob pattern prefixed with glob:, e.g. glob:https://legacy.site.com/*. Empty lines and lines starting
+// with # are ignored.
+type baseline struct {
+	urls     map[string]bool
+	patterns []*regexp.Regexp
+	// Listed URLs found healthy during the run, which can be removed from the baseline
+	fixed map[string]bool
+}
+
+func loadBaseline(path string) (*baseline, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		return nil, fmt.Errorf("Could not read baseline: %s", openError)
+	}
+	defer file.Close()
+
+	known := &baseline{urls: make(map[string]bool), fixed: make(map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		switch {
+		case entry == "" || strings.HasPrefix(entry, "#"):
+		case strings.HasPrefix(entry, linkhealth.GLOB_PATTERN_PREFIX):
+			pattern, patternError := linkhealth.CompileURLPattern(entry)
+			if patternError != nil {
+				return nil, fmt.Errorf("Invalid baseline entry on line %d: %s", line, patternError)
+			}
+			known.patterns = append(known.patterns, pattern)
+		default:
+			known.urls[linkhealth.NormalizeURL(entry, false)] = true
+		}
+	}
+	return known, scanner.Err()
+}
+
+// Checks whether the link is listed in the baseline
+func (known *baseline) contains(link string) bool {
+	if known.urls[linkhealth.NormalizeURL(link, false)] {
+		return true
+	}
+	for _, pattern := range known.patterns {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// Remembers listed URLs found healthy, so they can be reported as fixed
+func (known *baseline) record(result linkhealth.Result) {
+	if result.IsWarning() || result.URL == nil || result.Err != nil || !result.IsHealthy() {
+		return
+	}
+	if key := linkhealth.NormalizeURL(result.URL.String(), false); known.urls[key] {
+		known.fixed[result.URL.String()] = true
+	}
+}
+
+// Returns the listed URLs found healthy, in alphabetical order
+func (known *baseline) fixedURLs() []string {
+	fixed := make([]string, 0, len(known.fixed))
+	for link := range known.fixed {
+		fixed = append(fixed, link)
+	}
+	sort.Strings(fixed)
+	return fixed
+}
+
+// Writes the broken links of the run as a baseline file once the crawl finishes
+type baselineWriter struct {
+	path   string
+	broken map[string]bool
+}
+
+func newBaselineWriter(path string) *baselineWriter {
+	return &baselineWriter{path: path, broken: make(map[string]bool)}
+}
+
+func (writer *baselineWriter) write(result linkhealth.Result) {
+	if result.IsWarning() || result.URL == nil || (result.Err == nil && result.IsHealthy()) {
+		return
+	}
+	writer.broken[result.URL.String()] = true
+}
+
+func (writer *baselineWriter) close() error {
+	links := make([]string, 0, len(writer.broken))
+	for link := range writer.broken {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "# Links known to be broken, written by simple_link_health on %s\n", time.Now().Format("2006-01-02"))
+	for _, link := range links {
+		content.WriteString(link + "\n")
+	}
+	if writeError := ioutil.WriteFile(writer.path, []byte(content.String()), 0644); writeError != nil {
+		return fmt.Errorf("Could not write baseline: %s", writeError)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+)
+
+// Subcommand comparing two result files written with -output=json or -output=ndjson
+const COMPARE_COMMAND = "compare"
+
+// Differences between the links of two result files
+type resultComparison struct {
+	// Broken in the second file but healthy or not checked in the first one
+	NewlyBroken []jsonResult
+	// Broken in the first file and healthy in the second one
+	Fixed []jsonResult
+	// Broken in the first file and not checked in the second one
+	OnlyBroken []jsonResult
+	// Checked in both files with a different status, as the result in the second file along with the first one
+	StatusChanged [][2]jsonResult
+}
+
+// Runs the compare subcommand, printing the differences between two result files, e.g. of a staging and a
+// production crawl or of crawls before and after a deploy, and exiting with 1 when links broke
+func runCompare(arguments []string) {
+	flags := flag.NewFlagSet(COMPARE_COMMAND, flag.ExitOnError)
+	var rewrites stringList
+	flags.Var(&rewrites, "rewrite", "Replace a URL prefix of the first file before comparing as prefix=replacement, e.g. https://staging.site.com=https://www.site.com. Can be repeated")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [options] before.json after.json\n", os.Args[0], COMPARE_COMMAND)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	prefixes, rewriteError := parseRewrites(rewrites)
+	if rewriteError != nil {
+		handleFatal(rewriteError)
+	}
+
+	before, beforeError := loadResultFile(flags.Arg(0))
+	if beforeError != nil {
+		handleFatal(beforeError)
+	}
+	after, afterError := loadResultFile(flags.Arg(1))
+	if afterError != nil {
+		handleFatal(afterError)
+	}
+	for index := range before {
+		before[index].URL = rewriteURL(before[index].URL, prefixes)
+	}
+
+	comparison := compareResults(before, after)
+	fmt.Printf("Comparing %s (%d links) with %s (%d links)\n", flags.Arg(0), len(before), flags.Arg(1), len(after))
+	printComparedLinks("Newly broken", comparison.NewlyBroken, aurora.Red)
+	printComparedLinks("Fixed", comparison.Fixed, aurora.Green)
+	printComparedLinks("Broken only in "+flags.Arg(0), comparison.OnlyBroken, aurora.Yellow)
+
+	fmt.Println()
+	fmt.Printf("%s	%d\n", aurora.Bold("Status changed"), len(comparison.StatusChanged))
+	for _, changed := range comparison.StatusChanged {
+		fmt.Printf("%s	%s -> %s\n", changed[1].URL, getComparedReason(changed[0]), aurora.Yellow(getComparedReason(changed[1])))
+	}
+
+	if len(comparison.NewlyBroken) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Reads the results of a file written with -output=json or -output=ndjson, skipping warnings. A link reported
+// several times, e.g. once for each missing fragment, is kept once and counted as broken if any of its
+// results is.
+func loadResultFile(path string) ([]jsonResult, error) {
+	content, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, fmt.Errorf("Could not read results: %s", readError)
+	}
+
+	var results []jsonResult
+	content = bytes.TrimSpace(content)
+	if bytes.HasPrefix(content, []byte("[")) {
+		if parseError := json.Unmarshal(content, &results); parseError != nil {
+			return nil, fmt.Errorf("Could not parse results of %s: %s", path, parseError)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		for {
+			var result jsonResult
+			if decodeError := decoder.Decode(&result); decodeError == io.EOF {
+				break
+			} else if decodeError != nil {
+				return nil, fmt.Errorf("Could not parse results of %s: %s", path, decodeError)
+			}
+			results = append(results, result)
+		}
+	}
+
+	index := make(map[string]int)
+	var links []jsonResult
+	for _, result := range results {
+		if result.Warning != "" || result.URL == "" {
+			continue
+		}
+		if existing, seen := index[result.URL]; !seen {
+			index[result.URL] = len(links)
+			links = append(links, result)
+		} else if links[existing].Healthy && !result.Healthy {
+			links[existing] = result
+		}
+	}
+	return links, nil
+}
+
+func compareResults(before []jsonResult, after []jsonResult) resultComparison {
+	previous := make(map[string]jsonResult, len(before))
+	for _, result := range before {
+		previous[linkhealth.NormalizeURL(result.URL, false)] = result
+	}
+	checked := make(map[string]bool, len(after))
+
+	var comparison resultComparison
+	for _, result := range after {
+		key := linkhealth.NormalizeURL(result.URL, false)
+		checked[key] = true
+		earlier, seen := previous[key]
+		switch {
+		case !result.Healthy && (!seen || earlier.Healthy):
+			comparison.NewlyBroken = append(comparison.NewlyBroken, result)
+		case result.Healthy && seen && !earlier.Healthy:
+			comparison.Fixed = append(comparison.Fixed, result)
+		case seen && getComparedReason(result) != getComparedReason(earlier):
+			comparison.StatusChanged = append(comparison.StatusChanged, [2]jsonResult{earlier, result})
+		}
+	}
+	for _, result := range before {
+		if !result.Healthy && !checked[linkhealth.NormalizeURL(result.URL, false)] {
+			comparison.OnlyBroken = append(comparison.OnlyBroken, result)
+		}
+	}
+
+	for _, links := range [][]jsonResult{comparison.NewlyBroken, comparison.Fixed, comparison.OnlyBroken} {
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].URL < links[j].URL
+		})
+	}
+	sort.Slice(comparison.StatusChanged, func(i, j int) bool {
+		return comparison.StatusChanged[i][1].URL < comparison.StatusChanged[j][1].URL
+	})
+	return comparison
+}
+
+// Parses prefix=replacement rewrites, returning the prefixes with their replacements
+func parseRewrites(rewrites []string) ([][2]string, error) {
+	var prefixes [][2]string
+	for _, rewrite := range rewrites {
+		parts := strings.SplitN(rewrite, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid rewrite %q, expected prefix=replacement", rewrite)
+		}
+		prefixes = append(prefixes, [2]string{parts[0], parts[1]})
+	}
+	return prefixes, nil
+}
+
+// Replaces the first matching prefix of the link
+func rewriteURL(link string, prefixes [][2]string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(link, prefix[0]) {
+			return prefix[1] + strings.TrimPrefix(link, prefix[0])
+		}
+	}
+	return link
+}
+
+func printComparedLinks(title string, links []jsonResult, color func(interface{}) aurora.Value) {
+	fmt.Println()
+	fmt.Printf("%s	%d\n", aurora.Bold(title), len(links))
+	for _, link := range links {
+		fmt.Printf("%s	%s\n", link.URL, color(getComparedReason(link)))
+		for _, parent := range link.Parents {
+			fmt.Printf("	linked from %s\n", parent)
+		}
+	}
+}
+
+// Describes the outcome of a compared link by its status, or its error when it has none
+func getComparedReason(link jsonResult) string {
+	if link.Status == 0 && link.Error != "" {
+		return link.Error
+	}
+	if link.Healthy || link.Error == "" {
+		return fmt.Sprintf("%d", link.Status)
+	}
+	return fmt.Sprintf("%d %s", link.Status, link.Error)
+}
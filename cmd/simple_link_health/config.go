@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file key listing the starting URLs
+const CONFIG_URLS_KEY = "urls"
+
+// Loads a YAML or TOML config file, chosen by its extension. Every key is the name of a flag, except for
+// urls listing the starting URLs. Flags given on the command line take precedence over the config file.
+// Returns the starting URLs listed in the config file.
+func applyConfig(path string, flags *flag.FlagSet) ([]string, error) {
+	values, loadError := loadConfig(path)
+	if loadError != nil {
+		return nil, loadError
+	}
+
+	explicit := make(map[string]bool)
+	flags.Visit(func(explicitFlag *flag.Flag) {
+		explicit[explicitFlag.Name] = true
+	})
+
+	// Apply the keys in a stable order so errors are reported consistently
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var urls []string
+	for _, key := range keys {
+		if key == CONFIG_URLS_KEY {
+			urls = getConfigList(values[key])
+			continue
+		}
+		// Sites are crawled with their own options, see loadSites
+		if key == CONFIG_SITES_KEY {
+			continue
+		}
+		if flags.Lookup(key) == nil || key == "config" {
+			return nil, fmt.Errorf("Unknown option %q in config file %s", key, path)
+		}
+		if explicit[key] {
+			continue
+		}
+		if setError := setConfigValue(flags, key, values[key]); setError != nil {
+			return nil, fmt.Errorf("Invalid value for %q in config file %s: %s", key, path, setError)
+		}
+	}
+
+	return urls, nil
+}
+
+// Sets a flag from a config value. Repeatable flags are set once per list item,
+// other flags are set to the comma separated list items.
+func setConfigValue(flags *flag.FlagSet, name string, value interface{}) error {
+	list := getConfigList(value)
+	if _, repeatable := flags.Lookup(name).Value.(*stringList); !repeatable {
+		return flags.Set(name, strings.Join(list, ","))
+	}
+
+	for _, item := range list {
+		if setError := flags.Set(name, item); setError != nil {
+			return setError
+		}
+	}
+	return nil
+}
+
+func loadConfig(path string) (map[string]interface{}, error) {
+	content, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, readError
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if decodeError := yaml.Unmarshal(content, &values); decodeError != nil {
+			return nil, fmt.Errorf("Invalid config file %s: %s", path, decodeError)
+		}
+	case ".toml":
+		if decodeError := toml.Unmarshal(content, &values); decodeError != nil {
+			return nil, fmt.Errorf("Invalid config file %s: %s", path, decodeError)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown config file format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	return values, nil
+}
+
+// Returns the values of a list, or the value itself when it is a single value. Lists are used for
+// comma separated flags such as failOn.
+func getConfigList(value interface{}) []string {
+	switch typed := value.(type) {
+	case []interface{}:
+		list := make([]string, 0, len(typed))
+		for _, item := range typed {
+			list = append(list, fmt.Sprint(item))
+		}
+		return list
+	case []string:
+		return typed
+	default:
+		return []string{fmt.Sprint(typed)}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+var CSV_HEADER = []string{"url", "parent", "status", "latencyMs", "ttfbMs", "category", "error", "archivedUrl"}
+
+// Collects every result and writes them as CSV once the crawl finishes, with one row per page each link
+// was found on so the rows can be filtered and sorted in spreadsheets. Warnings are not included.
+type csvWriter struct {
+	output  io.Writer
+	checker *linkhealth.Checker
+	results []linkhealth.Result
+}
+
+func (writer *csvWriter) write(result linkhealth.Result) {
+	if !result.IsWarning() && result.URL != nil {
+		writer.results = append(writer.results, result)
+	}
+}
+
+func (writer *csvWriter) close() error {
+	output := csv.NewWriter(writer.output)
+	if writeError := output.Write(CSV_HEADER); writeError != nil {
+		return writeError
+	}
+
+	for _, result := range writer.results {
+		failure := ""
+		if result.Err != nil || !result.IsHealthy() {
+			failure = getFailureReason(result)
+		}
+		status := ""
+		if result.Status != 0 {
+			status = strconv.Itoa(result.Status)
+		}
+
+		parents := writer.checker.LinkedFrom(result.URL)
+		if len(parents) == 0 {
+			parents = []string{""}
+		}
+		for _, parent := range parents {
+			row := []string{result.URL.String(), parent, status, strconv.FormatInt(result.Latency.Milliseconds(), 10), strconv.FormatInt(result.TimeToFirstByte.Milliseconds(), 10), result.Category(), failure, result.ArchivedURL}
+			if writeError := output.Write(row); writeError != nil {
+				return writeError
+			}
+		}
+	}
+
+	output.Flush()
+	return output.Error()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Environment variables holding the SMTP credentials when they are not given as flags
+	SMTP_USERNAME_VARIABLE = "SMTP_USERNAME"
+	SMTP_PASSWORD_VARIABLE = "SMTP_PASSWORD"
+	// Port of SMTP over implicit TLS, other ports upgrade the connection with STARTTLS when supported
+	SMTPS_PORT    = "465"
+	EMAIL_SUBJECT = "simple_link_health: %s"
+)
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Text}}</h2>
+{{with .Summary}}<p>Checked {{.Checked}} links in {{.Duration.Round 1000000}}: {{.Healthy}} healthy, {{.Broken}} broken, {{.Warnings}} warnings</p>
+{{if .Categories}}<table cellpadding="4" style="border-collapse: collapse; margin-bottom: 1em">
+<tr style="text-align: left; border-bottom: 1px solid #ccc"><th>Category</th><th>Broken</th></tr>
+{{$summary := .}}{{range .SortedCategories}}<tr><td>{{.}}</td><td>{{index $summary.Categories .}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}<table cellpadding="6" style="border-collapse: collapse">
+<tr style="text-align: left; border-bottom: 1px solid #ccc"><th>Link</th><th>Reason</th><th>Linked from</th></tr>
+{{range .Links}}<tr style="border-bottom: 1px solid #eee"><td><a href="{{.URL}}">{{.URL}}</a></td><td>{{.Reason}}</td><td>{{range .Parents}}<a href="{{.}}">{{.}}</a><br>{{end}}</td></tr>
+{{end}}</table>
+<p style="color: #666">Checked on {{.Timestamp.Format "2006-01-02 15:04:05 MST"}}</p>
+</body>
+</html>
+`))
+
+// Emails notifications over SMTP, with a plain text and an HTML version of the broken links, so site
+// owners are notified without a chat or webhook integration
+type emailNotifier struct {
+	to       []string
+	from     string
+	server   string
+	username string
+	password string
+}
+
+// Creates the notifier of the email flags, nil when no recipient is given. The credentials default to the
+// SMTP_USERNAME and SMTP_PASSWORD environment variables, so they can be left out of scripts and config files.
+func newEmailNotifier(to []string, from string, server string, username string, password string) (*emailNotifier, error) {
+	if len(to) == 0 {
+		return nil, nil
+	}
+	if server == "" {
+		return nil, fmt.Errorf("Pass the SMTP server to send emails through with -smtpServer, e.g. smtp.site.com:587")
+	}
+	if _, _, splitError := net.SplitHostPort(server); splitError != nil {
+		return nil, fmt.Errorf("Invalid smtpServer value %q, expected host:port", server)
+	}
+	if from == "" {
+		from = to[0]
+	}
+	if username == "" {
+		username = os.Getenv(SMTP_USERNAME_VARIABLE)
+	}
+	if password == "" {
+		password = os.Getenv(SMTP_PASSWORD_VARIABLE)
+	}
+	return &emailNotifier{to: to, from: from, server: server, username: username, password: password}, nil
+}
+
+func (email *emailNotifier) notify(message notification) error {
+	content, formatError := formatEmail(email.from, email.to, message)
+	if formatError != nil {
+		return formatError
+	}
+	if sendError := email.send(content); sendError != nil {
+		return fmt.Errorf("Could not send email to %s: %s", strings.Join(email.to, ", "), sendError)
+	}
+	return nil
+}
+
+// Delivers the message to every recipient, authenticating when credentials are given. Connections use
+// implicit TLS on port 465 and are otherwise upgraded with STARTTLS when the server supports it.
+func (email *emailNotifier) send(content []byte) error {
+	host, port, _ := net.SplitHostPort(email.server)
+	dialer := &net.Dialer{Timeout: NOTIFY_TIMEOUT}
+	var connection net.Conn
+	var dialError error
+	if port == SMTPS_PORT {
+		connection, dialError = tls.DialWithDialer(dialer, "tcp", email.server, &tls.Config{ServerName: host})
+	} else {
+		connection, dialError = dialer.Dial("tcp", email.server)
+	}
+	if dialError != nil {
+		return dialError
+	}
+	_ = connection.SetDeadline(time.Now().Add(NOTIFY_TIMEOUT))
+
+	client, clientError := smtp.NewClient(connection, host)
+	if clientError != nil {
+		connection.Close()
+		return clientError
+	}
+	defer client.Close()
+
+	if port != SMTPS_PORT {
+		if supported, _ := client.Extension("STARTTLS"); supported {
+			if tlsError := client.StartTLS(&tls.Config{ServerName: host}); tlsError != nil {
+				return tlsError
+			}
+		}
+	}
+	if email.username != "" {
+		if authError := client.Auth(smtp.PlainAuth("", email.username, email.password, host)); authError != nil {
+			return authError
+		}
+	}
+
+	if mailError := client.Mail(email.from); mailError != nil {
+		return mailError
+	}
+	for _, recipient := range email.to {
+		if recipientError := client.Rcpt(recipient); recipientError != nil {
+			return recipientError
+		}
+	}
+	body, dataError := client.Data()
+	if dataError != nil {
+		return dataError
+	}
+	if _, writeError := body.Write(content); writeError != nil {
+		return writeError
+	}
+	if closeError := body.Close(); closeError != nil {
+		return closeError
+	}
+	return client.Quit()
+}
+
+// Formats the notification as a multipart email, letting mail clients choose between its text and HTML parts
+func formatEmail(from string, to []string, message notification) ([]byte, error) {
+	var content bytes.Buffer
+	parts := multipart.NewWriter(&content)
+	fmt.Fprintf(&content, "From: %s\r\n", from)
+	fmt.Fprintf(&content, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&content, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", fmt.Sprintf(EMAIL_SUBJECT, message.Text)))
+	fmt.Fprintf(&content, "Date: %s\r\n", message.Timestamp.Format(time.RFC1123Z))
+	fmt.Fprintf(&content, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&content, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+
+	var html bytes.Buffer
+	if renderError := emailTemplate.Execute(&html, message); renderError != nil {
+		return nil, renderError
+	}
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", formatEmailText(message)},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		writer, partError := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if partError != nil {
+			return nil, partError
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, writeError := encoder.Write([]byte(part.body)); writeError != nil {
+			return nil, writeError
+		}
+		if closeError := encoder.Close(); closeError != nil {
+			return nil, closeError
+		}
+	}
+	if closeError := parts.Close(); closeError != nil {
+		return nil, closeError
+	}
+	return content.Bytes(), nil
+}
+
+func formatEmailText(message notification) string {
+	lines := []string{message.Text, ""}
+	if summary := message.Summary; summary != nil {
+		lines = append(lines, fmt.Sprintf("Checked %d links in %s: %d healthy, %d broken, %d warnings", summary.Checked, summary.Duration.Round(time.Millisecond), summary.Healthy, summary.Broken, summary.Warnings))
+		if len(summary.Categories) > 0 {
+			lines = append(lines, "Broken by category")
+			for _, category := range summary.SortedCategories() {
+				lines = append(lines, fmt.Sprintf("    %s %d", category, summary.Categories[category]))
+			}
+		}
+		lines = append(lines, "")
+	}
+	for _, link := range message.Links {
+		lines = append(lines, fmt.Sprintf("%s %s", link.URL, link.Reason))
+		for _, parent := range link.Parents {
+			lines = append(lines, "    linked from "+parent)
+		}
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+const (
+	EXIT_CODE_BROKEN_LINKS = 1
+	// Matches requests that failed without a response, e.g. DNS or connection errors
+	FAIL_ON_ERROR = "error"
+)
+
+// Decides whether the crawl failed, based on the number of broken links matching the fail-on classes
+type failurePolicy struct {
+	maxBroken int
+	// Status classes such as 4xx, exact status codes and "error". Empty matches every broken link
+	failOn []string
+	broken int
+	// Broken links accepted by the baseline, not counted as broken. Nil without a baseline
+	baseline *baseline
+	known    int
+}
+
+// Parses a comma separated list of status classes (4xx, 5xx), status codes (404) and "error"
+func newFailurePolicy(maxBroken int, failOn string) (*failurePolicy, error) {
+	if maxBroken < 0 {
+		return nil, fmt.Errorf("maxBroken must not be negative")
+	}
+
+	policy := &failurePolicy{maxBroken: maxBroken}
+	for _, class := range strings.Split(failOn, ",") {
+		class = strings.ToLower(strings.TrimSpace(class))
+		if class == "" {
+			continue
+		}
+		if !isValidFailOnClass(class) {
+			return nil, fmt.Errorf("Invalid failOn value %q, expected a status class like 4xx, a status code or %s", class, FAIL_ON_ERROR)
+		}
+		policy.failOn = append(policy.failOn, class)
+	}
+
+	return policy, nil
+}
+
+func isValidFailOnClass(class string) bool {
+	if class == FAIL_ON_ERROR {
+		return true
+	}
+	if len(class) == 3 && strings.HasSuffix(class, "xx") {
+		return class[0] >= '1' && class[0] <= '5'
+	}
+	code, parseError := strconv.Atoi(class)
+	return parseError == nil && code >= 100 && code <= 599
+}
+
+// Counts the result when it is an error, either a broken link matching the fail-on classes or a warning
+// promoted to an error. Broken links with a lower severity do not count.
+func (policy *failurePolicy) record(result linkhealth.Result) {
+	if policy.baseline != nil {
+		policy.baseline.record(result)
+	}
+	if result.Severity != linkhealth.SEVERITY_ERROR {
+		return
+	}
+	if result.IsWarning() {
+		policy.broken++
+		return
+	}
+	if policy.baseline != nil && result.URL != nil && policy.baseline.contains(result.URL.String()) {
+		policy.known++
+		return
+	}
+	if policy.matches(result) {
+		policy.broken++
+	}
+}
+
+func (policy *failurePolicy) matches(result linkhealth.Result) bool {
+	if len(policy.failOn) == 0 {
+		return true
+	}
+
+	status := strconv.Itoa(result.Status)
+	for _, class := range policy.failOn {
+		switch {
+		case class == FAIL_ON_ERROR:
+			if result.Status == 0 {
+				return true
+			}
+		case strings.HasSuffix(class, "xx"):
+			if result.Status != 0 && status[0] == class[0] {
+				return true
+			}
+		case class == status:
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether more links broke than allowed
+func (policy *failurePolicy) failed() bool {
+	return policy.broken > policy.maxBroken
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+)
+
+// Subcommand checking the links of local Markdown, HTML and reStructuredText files
+const FILES_COMMAND = "files"
+
+// Runs the files subcommand, printing every broken link with its file and line and exiting with 1 when
+// broken links were found
+func runFiles(arguments []string) {
+	flags := flag.NewFlagSet(FILES_COMMAND, flag.ExitOnError)
+	userAgent := flags.String("userAgent", linkhealth.DEFAULT_USER_AGENT, "User-Agent")
+	threads := flags.Int("threads", linkhealth.DEFAULT_THREADS, "Number of threads to use")
+	timeout := flags.Duration("timeout", linkhealth.DEFAULT_REQUEST_TIMEOUT, "Timeout of each request, e.g. 5s")
+	retries := flags.Int("retries", 0, "Retry requests failing with a timeout, connection error or temporary status this many times")
+	healthyCodes := flags.String("healthyCodes", linkhealth.DEFAULT_HEALTHY_CODES, "Comma separated status codes and ranges counted as healthy, e.g. 200-299,401")
+	offline := flags.Bool("offline", false, "Only check links to local files, skipping remote URLs")
+	root := flags.String("root", ".", "Directory links starting with / are relative to")
+	output := flags.String("output", OUTPUT_TEXT, "Output format, one of text, sarif for code scanning or github for GitHub Actions annotations")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [options] files or directories...\n", os.Args[0], FILES_COMMAND)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(arguments)
+	if *output != OUTPUT_TEXT && *output != OUTPUT_SARIF && *output != OUTPUT_GITHUB {
+		handleFatal(fmt.Errorf("Unknown output format %q, expected text, sarif or github", *output))
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, findError := linkhealth.FindLinkFiles(paths)
+	if findError != nil {
+		handleFatal(findError)
+	}
+
+	var links []linkhealth.FileLink
+	for _, file := range files {
+		fileLinks, extractError := linkhealth.ExtractFileLinks(file)
+		if extractError != nil {
+			handleError(fmt.Errorf("Could not read %s: %s", file, extractError))
+			continue
+		}
+		links = append(links, fileLinks...)
+	}
+
+	codes, codesError := linkhealth.ParseStatusCodes(*healthyCodes)
+	if codesError != nil {
+		handleFatal(codesError)
+	}
+	options := linkhealth.Options{
+		UserAgent:    *userAgent,
+		Threads:      *threads,
+		Timeout:      *timeout,
+		Retries:      *retries,
+		HealthyCodes: codes,
+	}
+
+	ctx, stop := withShutdownSignals(context.Background())
+	defer stop()
+
+	started := time.Now()
+	var broken []linkhealth.FileLinkResult
+	checked := 0
+	for result := range linkhealth.CheckFileLinks(ctx, links, options, linkhealth.FileCheckOptions{Offline: *offline, Root: *root}) {
+		checked++
+		if result.Err != nil {
+			broken = append(broken, result)
+		}
+	}
+
+	// Report broken links in file order rather than the order they were checked in
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].File != broken[j].File {
+			return broken[i].File < broken[j].File
+		}
+		return broken[i].Line < broken[j].Line
+	})
+	switch *output {
+	case OUTPUT_SARIF:
+		if sarifError := writeSarif(os.Stdout, broken); sarifError != nil {
+			handleError(fmt.Errorf("Could not write SARIF output: %s", sarifError))
+		}
+	case OUTPUT_GITHUB:
+		writeGithubFileAnnotations(os.Stdout, broken)
+	default:
+		for _, result := range broken {
+			fmt.Printf("%s:%d	%s	%s\n", result.File, result.Line, result.Target, aurora.Red(result.Err))
+		}
+
+		fmt.Println()
+		fmt.Printf("Checked %d links in %d files in %s, %d broken\n", checked, len(files), time.Since(started).Round(time.Millisecond), len(broken))
+	}
+	if ctx.Err() != nil {
+		os.Exit(EXIT_CODE_INTERRUPTED)
+	}
+	if len(broken) > 0 {
+		os.Exit(EXIT_CODE_BROKEN_LINKS)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Prints GitHub Actions workflow commands, so broken links show up as annotations of the workflow run and
+// of pull requests. Errors become ::error commands, warnings ::warning commands and info ::notice commands.
+// Healthy links are not printed.
+type githubWriter struct {
+	output  io.Writer
+	checker *linkhealth.Checker
+}
+
+func (writer *githubWriter) write(result linkhealth.Result) {
+	if result.IsWarning() {
+		writeGithubCommand(writer.output, result.Severity, map[string]string{"title": "Link warning"}, result.Warning)
+		return
+	}
+	if result.Err == nil && result.IsHealthy() {
+		return
+	}
+
+	message := fmt.Sprintf("%s is broken (%s): %s%s", result.URL, result.Category(), getFailureReason(result), getArchivedAt(result.ArchivedURL))
+	if parents := writer.checker.LinkedFrom(result.URL); len(parents) > 0 {
+		message += "\nLinked from " + strings.Join(parents, ", ")
+	}
+	writeGithubCommand(writer.output, result.Severity, map[string]string{"title": "Broken link"}, message)
+}
+
+func (writer *githubWriter) close() error {
+	return nil
+}
+
+// Prints the broken links of the files subcommand as annotations of the files and lines they were found on
+func writeGithubFileAnnotations(output io.Writer, broken []linkhealth.FileLinkResult) {
+	for _, result := range broken {
+		properties := map[string]string{"file": getRepositoryPath(result.File), "line": strconv.Itoa(result.Line), "title": "Broken link"}
+		writeGithubCommand(output, linkhealth.SEVERITY_ERROR, properties, fmt.Sprintf("Broken link %s: %s", result.Target, result.Err))
+	}
+}
+
+// Writes a workflow command of the severity, escaping its properties and message
+func writeGithubCommand(output io.Writer, severity string, properties map[string]string, message string) {
+	command := "error"
+	switch severity {
+	case linkhealth.SEVERITY_WARNING:
+		command = "warning"
+	case linkhealth.SEVERITY_INFO:
+		command = "notice"
+	}
+
+	var formatted []string
+	for _, name := range []string{"file", "line", "title"} {
+		if value, found := properties[name]; found {
+			formatted = append(formatted, name+"="+escapeGithubProperty(value))
+		}
+	}
+	fmt.Fprintf(output, "::%s %s::%s\n", command, strings.Join(formatted, ","), escapeGithubData(message))
+}
+
+func escapeGithubData(data string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(data)
+}
+
+func escapeGithubProperty(property string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(property)
+}
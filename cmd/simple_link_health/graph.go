@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Formats of the link graph
+const (
+	GRAPH_OUTPUT_DOT     = "dot"
+	GRAPH_OUTPUT_GRAPHML = "graphml"
+)
+
+// A checked link of the graph
+type graphNode struct {
+	url string
+	// Nil for pages that were not checked
+	link     *url.URL
+	status   int
+	broken   bool
+	category string
+}
+
+// Writes the link graph of the crawl to a file once the crawl finishes. Every checked link is a node, with
+// an edge from each page it was found on. Broken links and the edges leading to them are highlighted.
+// Pages without incoming edges, such as the starting URLs and pages only listed in sitemaps, show
+// sections of the site not linked from the rest of it.
+type graphWriter struct {
+	format  string
+	path    string
+	checker *linkhealth.Checker
+	nodes   map[string]*graphNode
+}
+
+func newGraphWriter(format string, path string, checker *linkhealth.Checker) (*graphWriter, error) {
+	if format != GRAPH_OUTPUT_DOT && format != GRAPH_OUTPUT_GRAPHML {
+		return nil, fmt.Errorf("Unknown graph output %q, expected dot or graphml", format)
+	}
+	if path == "" {
+		path = "links." + format
+	}
+	return &graphWriter{format: format, path: path, checker: checker, nodes: make(map[string]*graphNode)}, nil
+}
+
+func (writer *graphWriter) write(result linkhealth.Result) {
+	if result.IsWarning() || result.URL == nil {
+		return
+	}
+	writer.nodes[result.URL.String()] = &graphNode{
+		url:      result.URL.String(),
+		link:     result.URL,
+		status:   result.Status,
+		broken:   result.Err != nil || !result.IsHealthy(),
+		category: result.Category(),
+	}
+}
+
+// Returns the nodes sorted by URL, along with the pages linking to each node. Parents that were never
+// checked, e.g. because the crawl was interrupted, are added as nodes without a status.
+func (writer *graphWriter) graph() ([]*graphNode, map[string][]string) {
+	parents := make(map[string][]string)
+	for link, node := range writer.nodes {
+		parents[link] = writer.checker.LinkedFrom(node.link)
+	}
+	for _, linkParents := range parents {
+		for _, parent := range linkParents {
+			if _, found := writer.nodes[parent]; !found {
+				writer.nodes[parent] = &graphNode{url: parent}
+			}
+		}
+	}
+
+	nodes := make([]*graphNode, 0, len(writer.nodes))
+	for _, node := range writer.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].url < nodes[j].url })
+	return nodes, parents
+}
+
+func (writer *graphWriter) close() error {
+	file, createError := os.Create(writer.path)
+	if createError != nil {
+		return fmt.Errorf("Could not write link graph: %s", createError)
+	}
+	defer file.Close()
+
+	nodes, parents := writer.graph()
+	var writeError error
+	if writer.format == GRAPH_OUTPUT_GRAPHML {
+		writeError = writeGraphML(file, nodes, parents)
+	} else {
+		writeError = writeDot(file, nodes, parents)
+	}
+	if writeError != nil {
+		return fmt.Errorf("Could not write link graph: %s", writeError)
+	}
+	return nil
+}
+
+func writeDot(output io.Writer, nodes []*graphNode, parents map[string][]string) error {
+	var dot strings.Builder
+	dot.WriteString("digraph links {\n")
+	dot.WriteString("\tnode [shape=box];\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&dot, "\t%s", dotQuote(node.url))
+		if node.broken {
+			fmt.Fprintf(&dot, " [color=red, fontcolor=red, tooltip=%s]", dotQuote(fmt.Sprintf("%d %s", node.status, node.category)))
+		}
+		dot.WriteString(";\n")
+	}
+	for _, node := range nodes {
+		for _, parent := range parents[node.url] {
+			fmt.Fprintf(&dot, "\t%s -> %s", dotQuote(parent), dotQuote(node.url))
+			if node.broken {
+				dot.WriteString(" [color=red]")
+			}
+			dot.WriteString(";\n")
+		}
+	}
+	dot.WriteString("}\n")
+
+	_, writeError := io.WriteString(output, dot.String())
+	return writeError
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func writeGraphML(output io.Writer, nodes []*graphNode, parents map[string][]string) error {
+	document := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "status", For: "node", Name: "status", Type: "int"},
+			{ID: "broken", For: "node", Name: "broken", Type: "boolean"},
+			{ID: "category", For: "node", Name: "category", Type: "string"},
+			{ID: "brokenLink", For: "edge", Name: "broken", Type: "boolean"},
+		},
+		Graph: graphMLGraph{ID: "links", EdgeDefault: "directed"},
+	}
+
+	for _, node := range nodes {
+		document.Graph.Nodes = append(document.Graph.Nodes, graphMLNode{
+			ID: node.url,
+			Data: []graphMLData{
+				{Key: "url", Value: node.url},
+				{Key: "status", Value: strconv.Itoa(node.status)},
+				{Key: "broken", Value: strconv.FormatBool(node.broken)},
+				{Key: "category", Value: node.category},
+			},
+		})
+		for _, parent := range parents[node.url] {
+			document.Graph.Edges = append(document.Graph.Edges, graphMLEdge{
+				Source: parent,
+				Target: node.url,
+				Data:   []graphMLData{{Key: "brokenLink", Value: strconv.FormatBool(node.broken)}},
+			})
+		}
+	}
+
+	if _, writeError := io.WriteString(output, xml.Header); writeError != nil {
+		return writeError
+	}
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+	if encodeError := encoder.Encode(document); encodeError != nil {
+		return encodeError
+	}
+	_, writeError := io.WriteString(output, "\n")
+	return writeError
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Parses "Name: value" headers
+func parseHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, header := range headers {
+		parts := strings.SplitN(header, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("Invalid header %q, expected \"Name: value\"", header)
+		}
+		parsed[http.CanonicalHeaderKey(name)] = strings.TrimSpace(parts[1])
+	}
+	return parsed, nil
+}
+
+// Parses "host: Name=value" headers into header rules, e.g. "cdn.example.com: User-Agent=Mozilla/5.0".
+// The host is a path.Match pattern such as *.example.com
+func parseHostHeaders(hostHeaders []string) ([]linkhealth.HeaderRule, error) {
+	var rules []linkhealth.HeaderRule
+	for _, hostHeader := range hostHeaders {
+		parts := strings.SplitN(hostHeader, ":", 2)
+		host := strings.TrimSpace(parts[0])
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid host header %q, expected \"host: Name=value\"", hostHeader)
+		}
+		if patternError := linkhealth.ValidateHostPattern(host); patternError != nil {
+			return nil, fmt.Errorf("Invalid host header %q: %s", hostHeader, patternError)
+		}
+
+		header := strings.SplitN(parts[1], "=", 2)
+		name := strings.TrimSpace(header[0])
+		if len(header) != 2 || name == "" {
+			return nil, fmt.Errorf("Invalid host header %q, expected \"host: Name=value\"", hostHeader)
+		}
+		rules = append(rules, linkhealth.HeaderRule{Host: host, Headers: map[string]string{http.CanonicalHeaderKey(name): strings.TrimSpace(header[1])}})
+	}
+	return rules, nil
+}
+
+// Parses "name=value" cookies
+func parseCookies(cookies []string) ([]*http.Cookie, error) {
+	var parsed []*http.Cookie
+	for _, cookie := range cookies {
+		parts := strings.SplitN(cookie, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("Invalid cookie %q, expected \"name=value\"", cookie)
+		}
+		parsed = append(parsed, &http.Cookie{Name: name, Value: strings.TrimSpace(parts[1])})
+	}
+	return parsed, nil
+}
+
+// Returns header rules sending the headers to the hosts of the URLs only, so credentials are not leaked to other sites
+func getSiteHeaderRules(headers map[string]string, urls []*url.URL) []linkhealth.HeaderRule {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	var rules []linkhealth.HeaderRule
+	seen := make(map[string]bool)
+	for _, targetURL := range urls {
+		if host := targetURL.Hostname(); !seen[host] {
+			seen[host] = true
+			rules = append(rules, linkhealth.HeaderRule{Host: host, Headers: headers})
+		}
+	}
+	return rules
+}
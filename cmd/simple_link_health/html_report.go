@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Writes a self contained HTML report of the crawl to a file once the crawl finishes
+type htmlReportWriter struct {
+	path     string
+	checker  *linkhealth.Checker
+	summary  *linkhealth.Summary
+	results  []linkhealth.Result
+	warnings []string
+}
+
+func newHTMLReportWriter(path string, checker *linkhealth.Checker) *htmlReportWriter {
+	return &htmlReportWriter{path: path, checker: checker, summary: linkhealth.NewSummary()}
+}
+
+func (writer *htmlReportWriter) write(result linkhealth.Result) {
+	writer.summary.Add(result)
+	if result.IsWarning() {
+		writer.warnings = append(writer.warnings, result.Warning)
+		return
+	}
+	writer.results = append(writer.results, result)
+}
+
+// A row of the report tables
+type htmlReportLink struct {
+	URL         string
+	Status      int
+	StatusText  string
+	Healthy     bool
+	Reason      string
+	Category    string
+	ArchivedURL string
+	Parents     []string
+	LatencyMs   int64
+}
+
+type htmlReport struct {
+	Summary     *linkhealth.Summary
+	GeneratedAt time.Time
+	Duration    time.Duration
+	Broken      []htmlReportLink
+	Links       []htmlReportLink
+	Warnings    []string
+	StatusCodes []htmlReportStatusCode
+}
+
+type htmlReportStatusCode struct {
+	Status string
+	Count  int
+}
+
+func (writer *htmlReportWriter) close() error {
+	writer.summary.Finish()
+	report := htmlReport{
+		Summary:     writer.summary,
+		GeneratedAt: time.Now(),
+		Duration:    writer.summary.Duration.Round(time.Millisecond),
+		Warnings:    writer.warnings,
+	}
+
+	for _, result := range writer.results {
+		link := htmlReportLink{
+			Status:    result.Status,
+			Healthy:   result.Err == nil && result.IsHealthy(),
+			LatencyMs: result.Latency.Milliseconds(),
+		}
+		if result.URL != nil {
+			link.URL = result.URL.String()
+			link.Parents = writer.checker.LinkedFrom(result.URL)
+		}
+		if result.Status != 0 {
+			link.StatusText = http.StatusText(result.Status)
+		}
+
+		report.Links = append(report.Links, link)
+		if !link.Healthy {
+			link.Reason = getFailureReason(result)
+			link.Category = result.Category()
+			link.ArchivedURL = result.ArchivedURL
+			report.Broken = append(report.Broken, link)
+		}
+	}
+
+	for _, code := range writer.summary.SortedStatusCodes() {
+		status := "no response"
+		if code != 0 {
+			status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+		}
+		report.StatusCodes = append(report.StatusCodes, htmlReportStatusCode{Status: status, Count: writer.summary.StatusCodes[code]})
+	}
+
+	file, createError := os.Create(writer.path)
+	if createError != nil {
+		return createError
+	}
+	if executeError := HTML_REPORT_TEMPLATE.Execute(file, report); executeError != nil {
+		file.Close()
+		return executeError
+	}
+	return file.Close()
+}
+
+// Writes results to several writers, e.g. printing them and writing a report
+type multiResultWriter []resultWriter
+
+func (writers multiResultWriter) write(result linkhealth.Result) {
+	for _, writer := range writers {
+		writer.write(result)
+	}
+}
+
+func (writers multiResultWriter) close() error {
+	var firstError error
+	for _, writer := range writers {
+		if closeError := writer.close(); closeError != nil && firstError == nil {
+			firstError = closeError
+		}
+	}
+	return firstError
+}
+
+var HTML_REPORT_TEMPLATE = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Link health report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.35em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; }
+th:after { content: " \2195"; color: #aaa; }
+td.number { text-align: right; }
+.healthy { color: #1a7f37; }
+.broken { color: #cf222e; }
+.warning { color: #9a6700; }
+.summary td:first-child { font-weight: bold; width: 10em; }
+ul.parents { margin: 0; padding-left: 1.2em; }
+a { color: #0969da; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Link health report</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</p>
+
+<table class="summary">
+<tr><td>Checked</td><td>{{.Summary.Checked}} links in {{.Duration}}</td></tr>
+<tr><td>Healthy</td><td class="healthy">{{.Summary.Healthy}}</td></tr>
+<tr><td>Broken</td><td class="{{if .Summary.Broken}}broken{{end}}">{{.Summary.Broken}}</td></tr>
+<tr><td>Warnings</td><td class="{{if .Summary.Warnings}}warning{{end}}">{{.Summary.Warnings}}</td></tr>
+{{range .StatusCodes}}<tr><td></td><td>{{.Status}}: {{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Broken links ({{len .Broken}})</h2>
+{{if .Broken}}<table class="sortable">
+<thead><tr><th>URL</th><th>Status</th><th>Category</th><th>Reason</th><th>Linked from</th><th>Response time (ms)</th></tr></thead>
+<tbody>
+{{range .Broken}}<tr>
+<td><a href="{{.URL}}">{{.URL}}</a></td>
+<td class="number">{{if .Status}}{{.Status}}{{end}}</td>
+<td>{{.Category}}</td>
+<td class="broken">{{.Reason}}{{if .ArchivedURL}}<br><a href="{{.ArchivedURL}}">Archived snapshot</a>{{end}}</td>
+<td>{{if .Parents}}<ul class="parents">{{range .Parents}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>{{end}}</td>
+<td class="number">{{.LatencyMs}}</td>
+</tr>
+{{end}}</tbody>
+</table>{{else}}<p class="healthy">No broken links found.</p>{{end}}
+
+{{if .Warnings}}<h2>Warnings ({{len .Warnings}})</h2>
+<ul>
+{{range .Warnings}}<li class="warning">{{.}}</li>
+{{end}}</ul>{{end}}
+
+<h2>All links ({{len .Links}})</h2>
+<table class="sortable">
+<thead><tr><th>URL</th><th>Status</th><th>Healthy</th><th>Response time (ms)</th></tr></thead>
+<tbody>
+{{range .Links}}<tr>
+<td><a href="{{.URL}}">{{.URL}}</a></td>
+<td class="number">{{if .Status}}{{.Status}} {{.StatusText}}{{end}}</td>
+<td class="{{if .Healthy}}healthy{{else}}broken{{end}}">{{if .Healthy}}yes{{else}}no{{end}}</td>
+<td class="number">{{.LatencyMs}}</td>
+</tr>
+{{end}}</tbody>
+</table>
+
+<script>
+// Sorts a table by the clicked column, numerically when both values are numbers
+document.querySelectorAll("table.sortable").forEach(function (table) {
+	table.querySelectorAll("th").forEach(function (header, column) {
+		var ascending = true;
+		header.addEventListener("click", function () {
+			var body = table.tBodies[0];
+			var rows = Array.prototype.slice.call(body.rows);
+			rows.sort(function (a, b) {
+				var x = a.cells[column].textContent.trim(), y = b.cells[column].textContent.trim();
+				var comparison = (parseFloat(x) - parseFloat(y)) || x.localeCompare(y);
+				return ascending ? comparison : -comparison;
+			});
+			ascending = !ascending;
+			rows.forEach(function (row) { body.appendChild(row); });
+		});
+	});
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Flag value used to read the starting URLs from stdin
+const STDIN_URL = "-"
+
+// A flag that can be repeated, collecting every value given
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Where the starting URLs come from. URLs given on the command line take precedence over the
+// config file, and stdin is read when no URLs are given and stdin is piped.
+type urlSources struct {
+	// Values of the repeatable url flag followed by the positional arguments, "-" reads stdin
+	urls []string
+	// File listing one URL per line, "-" reads stdin
+	inputFile  string
+	configURLs []string
+}
+
+// Returns the starting URLs from every source, in the order given and without duplicates
+func getTargetURLs(sources urlSources) ([]*url.URL, error) {
+	links := sources.urls
+	if len(links) == 0 && sources.inputFile == "" {
+		links = sources.configURLs
+	}
+	if len(links) == 0 && sources.inputFile == "" && isStdinPiped() {
+		links = []string{STDIN_URL}
+	}
+
+	var targetURLs []*url.URL
+	readStdin := false
+	for _, link := range links {
+		if link == STDIN_URL {
+			readStdin = true
+			continue
+		}
+		targetURL, urlError := getURL(link)
+		if urlError != nil {
+			return nil, fmt.Errorf("%s: %s", urlError, link)
+		}
+		targetURLs = append(targetURLs, targetURL)
+	}
+
+	if sources.inputFile == STDIN_URL {
+		readStdin = true
+	} else if sources.inputFile != "" {
+		fileURLs, readError := readURLFile(sources.inputFile)
+		if readError != nil {
+			return nil, readError
+		}
+		targetURLs = append(targetURLs, fileURLs...)
+	}
+
+	if readStdin {
+		stdinURLs, readError := readURLs(os.Stdin)
+		if readError != nil {
+			return nil, readError
+		}
+		targetURLs = append(targetURLs, stdinURLs...)
+	}
+
+	if len(targetURLs) == 0 {
+		return nil, fmt.Errorf("No valid URLs given, pass -url, URL arguments, -inputFile or pipe URLs to stdin")
+	}
+	return dedupeURLs(targetURLs), nil
+}
+
+func readURLFile(path string) ([]*url.URL, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		return nil, openError
+	}
+	defer file.Close()
+	return readURLs(file)
+}
+
+// Reads one URL per line, skipping blank lines and # comments and reporting invalid lines
+func readURLs(reader io.Reader) ([]*url.URL, error) {
+	var targetURLs []*url.URL
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		targetURL, urlError := getURL(line)
+		if urlError != nil {
+			handleError(fmt.Errorf("%s: %s", urlError, line))
+			continue
+		}
+		targetURLs = append(targetURLs, targetURL)
+	}
+
+	return targetURLs, scanner.Err()
+}
+
+func dedupeURLs(targetURLs []*url.URL) []*url.URL {
+	seen := make(map[string]bool)
+	var unique []*url.URL
+	for _, targetURL := range targetURLs {
+		if !seen[targetURL.String()] {
+			seen[targetURL.String()] = true
+			unique = append(unique, targetURL)
+		}
+	}
+	return unique
+}
+
+// Checks whether stdin is a pipe or file rather than a terminal
+func isStdinPiped() bool {
+	info, statError := os.Stdin.Stat()
+	if statError != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Name of the test suite in JUnit reports
+const JUNIT_SUITE_NAME = "simple_link_health"
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+// Collects every result and writes them as a JUnit XML report once the crawl finishes. Each checked link
+// is a test case classified by its host, broken links are failures and requests without a response are errors.
+// Warnings are included as the suite output.
+type junitWriter struct {
+	output   io.Writer
+	checker  *linkhealth.Checker
+	started  time.Time
+	results  []linkhealth.Result
+	warnings []string
+}
+
+func (writer *junitWriter) write(result linkhealth.Result) {
+	if result.IsWarning() {
+		writer.warnings = append(writer.warnings, result.Warning)
+		return
+	}
+	writer.results = append(writer.results, result)
+}
+
+func (writer *junitWriter) close() error {
+	suite := junitTestSuite{
+		Name:      JUNIT_SUITE_NAME,
+		Time:      formatJUnitSeconds(time.Since(writer.started)),
+		Timestamp: writer.started.Format("2006-01-02T15:04:05"),
+		SystemOut: strings.Join(writer.warnings, "\n"),
+	}
+
+	for _, result := range writer.results {
+		testCase := junitTestCase{Time: formatJUnitSeconds(result.Latency)}
+		var parents []string
+		if result.URL != nil {
+			testCase.Name = result.URL.String()
+			testCase.ClassName = result.URL.Host
+			parents = writer.checker.LinkedFrom(result.URL)
+		}
+
+		if result.Err != nil || !result.IsHealthy() {
+			problem := &junitProblem{
+				Message: getFailureReason(result),
+				Type:    fmt.Sprint(result.Status),
+				Details: strings.TrimPrefix(getLinkedFrom(parents), "\t"),
+			}
+			if result.Status == 0 {
+				problem.Type = "error"
+				testCase.Error = problem
+				suite.Errors++
+			} else {
+				testCase.Failure = problem
+				suite.Failures++
+			}
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Tests = len(suite.Cases)
+
+	if _, writeError := io.WriteString(writer.output, xml.Header); writeError != nil {
+		return writeError
+	}
+	encoder := xml.NewEncoder(writer.output)
+	encoder.Indent("", "  ")
+	if encodeError := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); encodeError != nil {
+		return encodeError
+	}
+	_, writeError := io.WriteString(writer.output, "\n")
+	return writeError
+}
+
+func formatJUnitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Formats of log lines
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+// Levels of log messages, messages below the level of the logger are dropped
+const (
+	LOG_LEVEL_DEBUG = iota
+	LOG_LEVEL_INFO
+	LOG_LEVEL_WARNING
+	LOG_LEVEL_ERROR
+	LOG_LEVEL_FATAL
+)
+
+var LOG_LEVEL_NAMES = []string{"debug", "info", "warning", "error", "fatal"}
+
+// Writes diagnostics such as errors, warnings and debug messages, separately from the results of the crawl.
+// Result lines are printed to stdout, logs go to stderr or the log file.
+type logger struct {
+	lock   sync.Mutex
+	level  int
+	format string
+	output io.Writer
+	colors aurora.Aurora
+}
+
+var logs = &logger{level: LOG_LEVEL_INFO, format: LOG_FORMAT_TEXT, output: os.Stderr, colors: aurora.NewAurora(true)}
+
+type jsonLogLine struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+// Registers the logging flags, returning a function applying them once the flags are parsed
+func addLoggingFlags(flags *flag.FlagSet) func() error {
+	verbose := flags.Bool("verbose", false, "Also log debug messages, such as every queued link and retried request")
+	quiet := flags.Bool("quiet", false, "Only log errors, not warnings")
+	format := flags.String("logFormat", LOG_FORMAT_TEXT, "Format of log lines, text or json")
+	file := flags.String("logFile", "", "Write logs to this file instead of stderr")
+
+	return func() error {
+		return logs.configure(*verbose, *quiet, *format, *file)
+	}
+}
+
+func (logger *logger) configure(verbose bool, quiet bool, format string, file string) error {
+	if verbose && quiet {
+		return fmt.Errorf("Pass either verbose or quiet, not both")
+	}
+	if format != LOG_FORMAT_TEXT && format != LOG_FORMAT_JSON {
+		return fmt.Errorf("Invalid logFormat %q, expected text or json", format)
+	}
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	logger.format = format
+	switch {
+	case verbose:
+		logger.level = LOG_LEVEL_DEBUG
+	case quiet:
+		logger.level = LOG_LEVEL_ERROR
+	}
+	if file != "" {
+		output, openError := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if openError != nil {
+			return fmt.Errorf("Could not open log file: %s", openError)
+		}
+		logger.output = output
+		logger.colors = aurora.NewAurora(false)
+	}
+	return nil
+}
+
+// Whether messages of the level are logged, so expensive debug messages can be skipped
+func (logger *logger) enabled(level int) bool {
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	return level >= logger.level
+}
+
+func (logger *logger) log(level int, message string) {
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	if level < logger.level {
+		return
+	}
+
+	if logger.format == LOG_FORMAT_JSON {
+		_ = json.NewEncoder(logger.output).Encode(jsonLogLine{Time: time.Now(), Level: LOG_LEVEL_NAMES[level], Message: message})
+		return
+	}
+	fmt.Fprintln(logger.output, logger.prefix(level), message)
+}
+
+func (logger *logger) prefix(level int) aurora.Value {
+	name := strings.ToUpper(LOG_LEVEL_NAMES[level][:1]) + LOG_LEVEL_NAMES[level][1:] + ":"
+	switch level {
+	case LOG_LEVEL_DEBUG:
+		return logger.colors.Gray(12, name)
+	case LOG_LEVEL_INFO:
+		return logger.colors.Blue(name)
+	case LOG_LEVEL_WARNING:
+		return logger.colors.Yellow(name)
+	case LOG_LEVEL_ERROR:
+		return logger.colors.Red(name)
+	}
+	return logger.colors.BrightRed(name)
+}
+
+func (logger *logger) debug(message string) {
+	logger.log(LOG_LEVEL_DEBUG, message)
+}
+
+func (logger *logger) info(message string) {
+	logger.log(LOG_LEVEL_INFO, message)
+}
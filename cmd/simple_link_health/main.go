@@ -1,71 +1,666 @@
 package main
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
-	"github.com/gocolly/colly"
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
 	"github.com/logrusorgru/aurora"
 )
 
-const (
-	DEFAULT_USER_AGENT                   = "Simple_Link_Health_BOT"
-	DEFAULT_HEALTHY_HTTP_MIN_STATUS_CODE = 200
-	DEFAULT_HEALTHY_HTTP_MAX_STATUS_CODE = 299
-)
-
 func main() {
-	userAgent := flag.String("userAgent", DEFAULT_USER_AGENT, "User-Agent")
-	depth := flag.Int("depth", 2, "Max depth")
-	threads := flag.Int("threads", 4, "Number of threads to use")
-	url := flag.String("url", "", "URL to use")
+	if len(os.Args) > 1 && os.Args[1] == DIFF_COMMAND {
+		runDiff(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == COMPARE_COMMAND {
+		runCompare(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == FILES_COMMAND {
+		runFiles(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == SERVE_COMMAND {
+		runServe(os.Args[2:])
+		return
+	}
+
+	userAgent := flag.String("userAgent", linkhealth.DEFAULT_USER_AGENT, "User-Agent")
+	depth := flag.Int("depth", linkhealth.DEFAULT_DEPTH, "Max depth")
+	threads := flag.Int("threads", linkhealth.DEFAULT_THREADS, "Number of threads to use")
+	var urls stringList
+	flag.Var(&urls, "url", "URL to start from, or - to read URLs from stdin. Can be repeated, URLs can also be passed as arguments")
+	inputFile := flag.String("inputFile", "", "File listing one URL to start from per line, or - to read them from stdin")
+	reportCertExpiry := flag.Bool("reportCertExpiry", false, "Report the days until the TLS certificate of each HTTPS link expires")
+	certExpiryWarn := flag.Int("certExpiryWarn", linkhealth.DEFAULT_CERT_EXPIRY_WARN_DAYS, "Warn when a TLS certificate expires within this many days")
+	checkTLS := flag.Int("checkTLS", 0, "List the TLS certificate expiry of every HTTPS host after the crawl, warning about certificates expiring within this many days")
+	var allowedDomains stringList
+	flag.Var(&allowedDomains, "allowedDomains", "Host to crawl like the hosts of the starting URLs, e.g. *.site.com. Can be repeated, links to other hosts are still checked")
+	var disallowedDomains stringList
+	flag.Var(&disallowedDomains, "disallowedDomains", "Host never to request, e.g. legacy.site.com. Can be repeated")
+	var rateLimits stringList
+	flag.Var(&rateLimits, "rateLimit", "Rate limit of the hosts matching a pattern as host=requests/unit, e.g. docs.site.com=2/s or *=30/m. Can be repeated")
+	var hostThreads stringList
+	flag.Var(&hostThreads, "hostThreads", "Parallel requests to the hosts matching a pattern as host=requests, e.g. cdn.site.com=2. Can be repeated")
+	checkSchemes := flag.Bool("checkSchemes", false, "Validate mailto and tel links and warn about javascript and unknown link schemes")
+	checkMX := flag.Bool("checkMX", false, "Look up the mail servers of the domains of mailto links, implies checkSchemes")
+	wayback := flag.Bool("wayback", false, "Look up the closest Internet Archive snapshot of links responding with 404 or 410 or whose host does not resolve")
+	stripQueryFlag := flag.Bool("stripQuery", false, "Remove query strings from links, checking each page once regardless of its query")
+	var stripParams stringList
+	flag.Var(&stripParams, "stripParam", "Query or path parameter to remove from links before visiting them, e.g. utm_* or sessionid. Can be repeated")
+	stripTracking := flag.Bool("stripTracking", false, "Remove common tracking and session ID parameters from links, such as utm_*, fbclid and jsessionid")
+	sortQuery := flag.Bool("sortQuery", false, "Sort the query parameters of links before visiting them")
+	slowThreshold := flag.Duration("slowThreshold", 0, "Report healthy links taking longer than this to respond as slow, e.g. 2s")
+	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification, e.g. for internal hosts with self signed certificates")
+	caCert := flag.String("caCert", "", "PEM file of CA certificates to trust in addition to the system certificates")
+	reportMalformedHrefs := flag.Bool("reportMalformedHrefs", false, "Warn about hrefs containing stray whitespace or newlines")
+	checkContentLanguage := flag.Bool("checkContentLanguage", false, "Warn when the Content-Language header does not match the locale in the URL path")
+	localePattern := flag.String("localePattern", linkhealth.DEFAULT_LOCALE_PATTERN, "Regular expression whose first capture group extracts the locale from the URL path")
+	checkCase := flag.Bool("checkCase", false, "Warn about links differing only by case from an already seen URL instead of visiting them")
+	checkAssets := flag.Bool("checkAssets", false, "Also check images, scripts, stylesheets and iframes")
+	sitemap := flag.Bool("sitemap", false, "Also check every page listed in the sitemap.xml of each URL's site")
+	sitemapOnly := flag.Bool("sitemapOnly", false, "Only check the pages listed in the sitemap.xml of each URL's site, without crawling")
+	external := flag.String("external", linkhealth.EXTERNAL_CHECK, "How links to other hosts than the starting URLs are handled: check (without following their links), skip or crawl")
+	render := flag.String("render", linkhealth.RENDER_HTML, "How links are extracted from pages: html (as served) or browser (rendered by a headless Chrome, running JavaScript first)")
+	externalThreads := flag.Int("externalThreads", 0, "Maximum parallel requests to external hosts (defaults to threads)")
+	queueSize := flag.Int("queueSize", linkhealth.DEFAULT_QUEUE_SIZE, "Links kept in memory while waiting to be requested, further links wait in a temporary file")
+	var include, exclude stringList
+	flag.Var(&include, "include", "Only visit discovered links matching this regular expression, or glob when prefixed with glob:. Can be repeated")
+	flag.Var(&exclude, "exclude", "Do not visit discovered links matching this regular expression, or glob when prefixed with glob:. Can be repeated")
+	checkFragments := flag.Bool("checkFragments", false, "Report links to #fragments missing from the ids and anchor names of the page they point to")
+	checkCanonical := flag.Bool("checkCanonical", false, "Warn about links to pages whose rel=canonical is another URL, and pages immediately refreshing to another URL with a meta refresh tag")
+	checkHreflang := flag.Bool("checkHreflang", false, "Check the alternate language versions declared with hreflang, warning about invalid language codes and alternates not linking back")
+	followMetaRefresh := flag.Bool("followMetaRefresh", false, "Check the targets of meta refresh tags like the targets of redirects")
+	var expectText, rejectText stringList
+	flag.Var(&expectText, "expectText", "Report pages whose body does not match this regular expression as broken, e.g. \"</footer>\". Can be repeated")
+	flag.Var(&rejectText, "rejectText", "Report pages whose body matches this regular expression as broken, e.g. \"(?i)page not found\". Can be repeated")
+	minContentLength := flag.Int("minContentLength", 0, "Report responses with a body shorter than this many bytes as broken (0 for no minimum)")
+	minSize := flag.Int("minSize", 0, "Warn about healthy responses with a body smaller than this many bytes (0 for no minimum)")
+	sizeDeviation := flag.Int("sizeDeviation", 0, "Warn about healthy responses whose size differs from previous runs of the store by more than this many percent")
+	sizeHistory := flag.Int("sizeHistory", linkhealth.DEFAULT_SIZE_HISTORY_RUNS, "Number of previous runs of the store whose median size responses are compared with")
+	var contentTypes stringList
+	flag.Var(&contentTypes, "contentType", "Media type of healthy responses, e.g. text/html or image/*. Can be repeated, responses of other types are reported as broken")
+	healthyCodes := flag.String("healthyCodes", linkhealth.DEFAULT_HEALTHY_CODES, "Comma separated status codes and ranges of healthy links, e.g. 200-299,301,302")
+	warningCodes := flag.String("warningCodes", "", "Comma separated status codes and ranges reported as warnings instead of broken links, e.g. 403,429")
+	var severityRules stringList
+	flag.Var(&severityRules, "severity", "Severity of a status code, status class, error category or warning kind, as condition=error, warning or info, e.g. 404=warning or slow=error. Only errors fail the run. Can be repeated")
+	maxRedirects := flag.Int("maxRedirects", linkhealth.DEFAULT_MAX_REDIRECTS, "Report links redirecting more than this many times as broken")
+	warnPermanentRedirects := flag.Bool("warnPermanentRedirects", false, "Warn about links permanently redirecting (301, 308) within the same host, which should be updated")
+	respectRobots := flag.Bool("respectRobots", true, "Skip links disallowed by robots.txt and wait for the Crawl-delay of each host")
+	ignoreRobots := flag.Bool("ignoreRobots", false, "Ignore robots.txt, overriding respectRobots")
+	noHTTP2 := flag.Bool("noHttp2", false, "Only use HTTP/1.1, HTTP/2 is used with servers supporting it by default")
+	maxIdleConnsPerHost := flag.Int("maxIdleConnsPerHost", 0, "Idle connections kept open per host for reuse, defaults to the number of parallel requests")
+	idleTimeout := flag.Duration("idleTimeout", linkhealth.DEFAULT_IDLE_CONN_TIMEOUT, "How long idle connections are kept open for reuse")
+	noKeepAlive := flag.Bool("noKeepAlive", false, "Open a new connection for every request instead of reusing connections")
+	preferIP := flag.String("preferIp", "", "Connect over IPv4 (4) or IPv6 (6) first, falling back to any address of the host")
+	headFirst := flag.Bool("headFirst", false, "Make HEAD requests for links whose body is not needed, falling back to GET when HEAD fails")
+	timeout := flag.Duration("timeout", linkhealth.DEFAULT_REQUEST_TIMEOUT, "Timeout of each request, retries are timed separately")
+	maxDuration := flag.Duration("maxDuration", 0, "Stop making new requests after this long, reporting the links checked so far (0 for no limit)")
+	retries := flag.Int("retries", 0, "Retry requests failing with a timeout, connection error or 408, 429, 502, 503, 504 status this many times")
+	retryDelay := flag.Duration("retryDelay", linkhealth.DEFAULT_RETRY_DELAY, "Wait before the first retry, doubled for every further retry. A Retry-After header takes precedence")
+	softMaxLinks := flag.Int("softMaxLinks", 0, "Stop following new links after this many requests, while still checking links already found (0 for no limit)")
+	maxLinks := flag.Int("maxLinks", 0, "Stop making requests after this many requests (0 for no limit)")
+	flag.IntVar(maxLinks, "maxRequests", 0, "Alias of maxLinks")
+	maxPages := flag.Int("maxPages", 0, "Only follow the links of this many pages, still checking the links found on them (0 for no limit)")
+	var headers, hostHeaders, cookies stringList
+	flag.Var(&headers, "header", "Header sent to the hosts of the starting URLs, as \"Name: value\". Can be repeated")
+	flag.Var(&hostHeaders, "hostHeader", "Header sent to hosts matching a pattern, overriding the defaults such as the User-Agent, as \"host: Name=value\". Can be repeated")
+	flag.Var(&cookies, "cookie", "Cookie sent to the hosts of the starting URLs, as name=value. Can be repeated")
+	cookieJar := flag.String("cookieJar", "", "Netscape cookie file, as exported by curl or browser extensions, with cookies to send")
+	var proxies stringList
+	flag.Var(&proxies, "proxy", "Proxy URL to send requests through, http, https or socks5. Repeat to rotate between proxies, defaults to the HTTP_PROXY and HTTPS_PROXY environment variables")
+	basicAuth := flag.String("basicAuth", "", "Credentials sent to the hosts of the starting URLs with basic authentication, as user:password")
+	loginURL := flag.String("loginUrl", "", "Page with a login form submitted before crawling, its session cookies are used for the crawl")
+	loginForm := flag.String("loginForm", "", "URL encoded login form fields, e.g. username=alice&password=secret")
+	headerRulesPath := flag.String("headerRules", "", "JSON file mapping host patterns to headers sent to matching hosts")
+	output := flag.String("output", OUTPUT_TEXT, "Output format, one of text, json, ndjson, junit, csv, github")
+	reportHTML := flag.String("reportHtml", "", "Also write a self contained HTML report of the crawl to this file")
+	watch := flag.Bool("watch", false, "Keep running and crawl again on the interval, only printing links whose state changed")
+	interval := flag.String("interval", DEFAULT_WATCH_INTERVAL, "How often to crawl in watch mode, as a duration such as 15m or a cron expression such as \"0 * * * *\"")
+	notifyWebhook := flag.String("notifyWebhook", "", "URL to post a JSON notification to when broken links are found, or when links go down in watch mode")
+	notifySlack := flag.String("notifySlack", "", "Slack bot token and channel to notify when broken links are found, as token/channel")
+	var emailTo stringList
+	flag.Var(&emailTo, "emailTo", "Email address to send a report to when broken links are found, or when links go down in watch mode. Can be repeated")
+	emailFrom := flag.String("emailFrom", "", "Sender address of emailed reports, defaults to the first emailTo address")
+	smtpServer := flag.String("smtpServer", "", "SMTP server to send emails through as host:port, using TLS on port 465 and STARTTLS when supported otherwise")
+	smtpUser := flag.String("smtpUser", "", "SMTP username, defaults to the SMTP_USERNAME environment variable")
+	smtpPassword := flag.String("smtpPassword", "", "SMTP password, defaults to the SMTP_PASSWORD environment variable")
+	metricsAddr := flag.String("metricsAddr", "", "Serve Prometheus metrics of watch mode on this address, e.g. :9090")
+	graphOutput := flag.String("graphOutput", "", "Also write the graph of checked links, with broken links highlighted, as dot (Graphviz) or graphml (Gephi)")
+	graphFile := flag.String("graphFile", "", "File the link graph is written to, defaults to links.dot or links.graphml")
+	checkpoint := flag.String("checkpoint", "", "Periodically save the state of the crawl to this file, and resume from it when it exists. Removed once the crawl finishes")
+	storePath := flag.String("store", "", "Record the results of the crawl in this file, compare runs with the diff subcommand")
+	noProgress := flag.Bool("noProgress", false, "Do not show the progress of the crawl on stderr, e.g. in CI logs")
+	summaryOnly := flag.Bool("summaryOnly", false, "Only print the summary at the end of the crawl in text output")
+	maxBroken := flag.Int("maxBroken", 0, "Number of broken links allowed before exiting with a non-zero exit code")
+	baselinePath := flag.String("baseline", "", "File listing links known to be broken, as URLs or glob: patterns, which do not count as broken for the exit code")
+	writeBaseline := flag.String("writeBaseline", "", "Write the broken links of this run to a baseline file")
+	failOn := flag.String("failOn", "", "Comma separated status classes (4xx, 5xx), status codes and \"error\" counted as broken when deciding the exit code, defaults to every broken link")
+	benchmark := flag.String("benchmark", "", "Benchmark this URL by repeatedly requesting it instead of crawling")
+	requests := flag.Int("requests", linkhealth.DEFAULT_BENCHMARK_REQUESTS, "Number of requests to make in benchmark mode")
+	concurrency := flag.Int("concurrency", linkhealth.DEFAULT_BENCHMARK_CONCURRENCY, "Number of concurrent requests in benchmark mode")
+	applyLogging := addLoggingFlags(flag.CommandLine)
+	configPath := flag.String("config", "", "YAML or TOML file setting any of these options, plus urls listing the starting URLs or sites listing sites to crawl. Command line flags take precedence")
+	parallelSites := flag.Int("parallelSites", DEFAULT_PARALLEL_SITES, "Number of sites of a config file crawled at the same time")
 
 	flag.Parse()
-	targetURL, urlError := getURL(*url)
+
+	var configURLs []string
+	if *configPath != "" {
+		urls, configError := applyConfig(*configPath, flag.CommandLine)
+		if configError != nil {
+			handleFatal(configError)
+		}
+		configURLs = urls
+	}
+	if loggingError := applyLogging(); loggingError != nil {
+		handleFatal(loggingError)
+	}
+
+	if *configPath != "" {
+		sites, sitesError := loadSites(*configPath)
+		if sitesError != nil {
+			handleFatal(sitesError)
+		}
+		if len(sites) > 0 {
+			if flagsError := checkSitesFlags(flag.CommandLine, append(configURLs, flag.Args()...)); flagsError != nil {
+				handleFatal(flagsError)
+			}
+			ctx, stop := withShutdownSignals(context.Background())
+			defer stop()
+			runSites(ctx, sites, sitesOptions{parallel: *parallelSites, output: *output, maxBroken: *maxBroken, failOn: *failOn})
+			return
+		}
+	}
+
+	siteHeaders, headersError := parseHeaders(headers)
+	if headersError != nil {
+		handleFatal(headersError)
+	}
+
+	if *basicAuth != "" {
+		if !strings.Contains(*basicAuth, ":") {
+			handleFatal(fmt.Errorf("Invalid basicAuth value, expected user:password"))
+		}
+		siteHeaders["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(*basicAuth))
+	}
+
+	var loginFormOption *linkhealth.LoginForm
+	if *loginURL != "" {
+		fields, fieldsError := url.ParseQuery(*loginForm)
+		if fieldsError != nil {
+			handleFatal(fmt.Errorf("Invalid loginForm value: %s", fieldsError))
+		}
+		loginFormOption = &linkhealth.LoginForm{URL: *loginURL, Fields: fields}
+	}
+
+	var hostLimits []linkhealth.HostLimit
+	for _, value := range rateLimits {
+		limit, limitError := linkhealth.ParseRateLimit(value)
+		if limitError != nil {
+			handleFatal(limitError)
+		}
+		hostLimits = append(hostLimits, limit)
+	}
+	for _, value := range hostThreads {
+		limit, limitError := linkhealth.ParseHostThreads(value)
+		if limitError != nil {
+			handleFatal(limitError)
+		}
+		hostLimits = append(hostLimits, limit)
+	}
+
+	var rootCAs *x509.CertPool
+	if *caCert != "" {
+		var caError error
+		if rootCAs, caError = linkhealth.LoadCACertificates(*caCert); caError != nil {
+			handleFatal(caError)
+		}
+	}
+
+	var proxyURLs []*url.URL
+	for _, proxy := range proxies {
+		proxyURL, proxyError := linkhealth.ParseProxy(proxy)
+		if proxyError != nil {
+			handleFatal(proxyError)
+		}
+		proxyURLs = append(proxyURLs, proxyURL)
+	}
+
+	siteCookies, cookiesError := parseCookies(cookies)
+	if cookiesError != nil {
+		handleFatal(cookiesError)
+	}
+	if *cookieJar != "" {
+		fileCookies, cookieFileError := linkhealth.LoadCookieFile(*cookieJar)
+		if cookieFileError != nil {
+			handleFatal(cookieFileError)
+		}
+		siteCookies = append(siteCookies, fileCookies...)
+	}
+
+	var headerRules []linkhealth.HeaderRule
+	if *headerRulesPath != "" {
+		rules, rulesError := linkhealth.LoadHeaderRules(*headerRulesPath)
+		if rulesError != nil {
+			handleFatal(rulesError)
+		}
+		headerRules = rules
+	}
+	// Rules given as flags take precedence over the rules of the file
+	flagRules, hostHeadersError := parseHostHeaders(hostHeaders)
+	if hostHeadersError != nil {
+		handleFatal(hostHeadersError)
+	}
+	headerRules = append(headerRules, flagRules...)
+
+	var compiledLocalePattern *regexp.Regexp
+	if *checkContentLanguage {
+		pattern, patternError := linkhealth.CompileLocalePattern(*localePattern)
+		if patternError != nil {
+			handleFatal(patternError)
+		}
+		compiledLocalePattern = pattern
+	}
+
+	includePatterns, includeError := linkhealth.CompileURLPatterns(include)
+	if includeError != nil {
+		handleFatal(includeError)
+	}
+	excludePatterns, excludeError := linkhealth.CompileURLPatterns(exclude)
+	if excludeError != nil {
+		handleFatal(excludeError)
+	}
+
+	expectPatterns, expectError := linkhealth.CompileTextPatterns(expectText)
+	if expectError != nil {
+		handleFatal(expectError)
+	}
+	rejectPatterns, rejectError := linkhealth.CompileTextPatterns(rejectText)
+	if rejectError != nil {
+		handleFatal(rejectError)
+	}
+
+	healthyStatusCodes, healthyError := linkhealth.ParseStatusCodes(*healthyCodes)
+	if healthyError != nil {
+		handleFatal(healthyError)
+	}
+	warningStatusCodes, warningError := linkhealth.ParseStatusCodes(*warningCodes)
+	if warningError != nil {
+		handleFatal(warningError)
+	}
+	severities, severityError := linkhealth.ParseSeverityRules(severityRules)
+	if severityError != nil {
+		handleFatal(severityError)
+	}
+
+	options := linkhealth.Options{
+		UserAgent:              *userAgent,
+		Depth:                  *depth,
+		Threads:                *threads,
+		CertExpiryWarn:         getCertExpiryWarn(*certExpiryWarn, *checkTLS),
+		HeaderRules:            headerRules,
+		ReportMalformedHrefs:   *reportMalformedHrefs,
+		LocalePattern:          compiledLocalePattern,
+		CheckCase:              *checkCase,
+		CheckAssets:            *checkAssets,
+		Sitemap:                *sitemap,
+		SitemapOnly:            *sitemapOnly,
+		External:               *external,
+		Render:                 *render,
+		ExternalThreads:        *externalThreads,
+		QueueSize:              *queueSize,
+		Include:                includePatterns,
+		Exclude:                excludePatterns,
+		CheckFragments:         *checkFragments,
+		CheckCanonical:         *checkCanonical,
+		FollowMetaRefresh:      *followMetaRefresh,
+		CheckHreflang:          *checkHreflang,
+		ExpectText:             expectPatterns,
+		RejectText:             rejectPatterns,
+		MinContentLength:       *minContentLength,
+		MinSize:                *minSize,
+		SizeDeviation:          *sizeDeviation,
+		ContentTypes:           contentTypes,
+		HealthyCodes:           healthyStatusCodes,
+		WarningCodes:           warningStatusCodes,
+		Severities:             severities,
+		MaxRedirects:           *maxRedirects,
+		WarnPermanentRedirects: *warnPermanentRedirects,
+		IgnoreRobots:           *ignoreRobots || !*respectRobots,
+		HeadFirst:              *headFirst,
+		DisableHTTP2:           *noHTTP2,
+		MaxIdleConnsPerHost:    *maxIdleConnsPerHost,
+		IdleConnTimeout:        *idleTimeout,
+		DisableKeepAlives:      *noKeepAlive,
+		PreferIPVersion:        *preferIP,
+		Cookies:                siteCookies,
+		Login:                  loginFormOption,
+		Proxies:                proxyURLs,
+		SlowThreshold:          *slowThreshold,
+		StripQuery:             *stripQueryFlag,
+		StripParams:            getStripParams(stripParams, *stripTracking),
+		SortQuery:              *sortQuery,
+		CheckSchemes:           *checkSchemes || *checkMX,
+		Wayback:                *wayback,
+		CheckMX:                *checkMX,
+		HostLimits:             hostLimits,
+		AllowedDomains:         allowedDomains,
+		DisallowedDomains:      disallowedDomains,
+		Insecure:               *insecure,
+		RootCAs:                rootCAs,
+		Timeout:                *timeout,
+		Retries:                *retries,
+		RetryDelay:             *retryDelay,
+		SoftMaxLinks:           *softMaxLinks,
+		MaxLinks:               *maxLinks,
+		MaxPages:               *maxPages,
+		Checkpoint:             *checkpoint,
+	}
+	if logs.enabled(LOG_LEVEL_DEBUG) {
+		options.Debug = logs.debug
+	}
+
+	if *benchmark != "" {
+		benchmarkURL, urlError := getURL(*benchmark)
+		if urlError != nil {
+			handleFatal(urlError)
+		}
+
+		options.URLs = []*url.URL{benchmarkURL}
+		options.HeaderRules = append(options.HeaderRules, getSiteHeaderRules(siteHeaders, options.URLs)...)
+		result, benchmarkError := linkhealth.Benchmark(context.Background(), benchmarkURL.String(), *requests, *concurrency, options)
+		if benchmarkError != nil {
+			handleFatal(benchmarkError)
+		}
+		printBenchmarkResult(benchmarkURL, result)
+		return
+	}
+
+	targetURLs, urlError := getTargetURLs(urlSources{
+		urls:       append(urls, flag.Args()...),
+		inputFile:  *inputFile,
+		configURLs: configURLs,
+	})
 	if urlError != nil {
 		handleFatal(urlError)
 	}
-	collector := getCollector(*userAgent, *depth, *threads)
-	collectorError := collector.Visit(targetURL.String())
-	if collectorError != nil {
-		handleError(collectorError)
+	options.URLs = targetURLs
+	options.HeaderRules = append(options.HeaderRules, getSiteHeaderRules(siteHeaders, targetURLs)...)
+
+	destinations, notifyError := getNotifiers(*notifyWebhook, *notifySlack)
+	if notifyError != nil {
+		handleFatal(notifyError)
+	}
+	email, emailError := newEmailNotifier(emailTo, *emailFrom, *smtpServer, *smtpUser, *smtpPassword)
+	if emailError != nil {
+		handleFatal(emailError)
+	}
+	if email != nil {
+		destinations = append(destinations, email)
+	}
+
+	if *storePath != "" {
+		validators, validatorsError := loadValidators(*storePath)
+		if validatorsError != nil {
+			handleFatal(validatorsError)
+		}
+		options.Validators = validators
+	}
+	if *sizeDeviation > 0 {
+		if *storePath == "" {
+			handleFatal(fmt.Errorf("Sizes are compared with previous runs of the store, pass -store along with -sizeDeviation"))
+		}
+		sizes, sizesError := loadSizes(*storePath, *sizeHistory)
+		if sizesError != nil {
+			handleFatal(sizesError)
+		}
+		options.PreviousSizes = sizes
+	}
+
+	if *watch {
+		schedule, scheduleError := parseWatchSchedule(*interval)
+		if scheduleError != nil {
+			handleFatal(scheduleError)
+		}
+
+		watch := watchOptions{schedule: schedule, maxDuration: *maxDuration, storePath: *storePath, notifiers: destinations}
+		if *metricsAddr != "" {
+			watch.metrics = newWatchMetrics()
+			serveMetrics(*metricsAddr, watch.metrics)
+		}
+
+		ctx, stop := withShutdownSignals(context.Background())
+		defer stop()
+		runWatch(ctx, options, watch)
+		return
+	}
+	if *metricsAddr != "" {
+		handleFatal(fmt.Errorf("Metrics are only served in watch mode, pass -watch along with -metricsAddr"))
+	}
+
+	policy, policyError := newFailurePolicy(*maxBroken, *failOn)
+	if policyError != nil {
+		handleFatal(policyError)
+	}
+	if *baselinePath != "" {
+		known, baselineError := loadBaseline(*baselinePath)
+		if baselineError != nil {
+			handleFatal(baselineError)
+		}
+		policy.baseline = known
+	}
+
+	checker := linkhealth.NewChecker()
+	writer, outputError := getResultWriter(*output, *reportCertExpiry, *checkTLS, *summaryOnly, checker)
+	if outputError != nil {
+		handleFatal(outputError)
+	}
+	if *reportHTML != "" {
+		writer = multiResultWriter{writer, newHTMLReportWriter(*reportHTML, checker)}
+	}
+	if *graphOutput != "" {
+		graph, graphError := newGraphWriter(*graphOutput, *graphFile, checker)
+		if graphError != nil {
+			handleFatal(graphError)
+		}
+		writer = multiResultWriter{writer, graph}
+	}
+	if *storePath != "" {
+		writer = multiResultWriter{writer, newStoreWriter(*storePath, options.Validators)}
+	}
+	if *writeBaseline != "" {
+		writer = multiResultWriter{writer, newBaselineWriter(*writeBaseline)}
+	}
+	if len(destinations) > 0 {
+		writer = multiResultWriter{writer, &notifyWriter{destinations: destinations, checker: checker, summary: linkhealth.NewSummary()}}
+	}
+	// Debug logs would break up the progress line
+	if !*noProgress && !logs.enabled(LOG_LEVEL_DEBUG) {
+		writer = newProgressWriter(writer, checker)
+	}
+
+	written := make(chan struct{})
+	go func() {
+		for result := range checker.Results() {
+			writer.write(result)
+			policy.record(result)
+		}
+		close(written)
+	}()
+
+	ctx, stop := withShutdownSignals(context.Background())
+	defer stop()
+	if *maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxDuration)
+		defer cancel()
+	}
+
+	runError := checker.Run(ctx, options)
+	<-written
+	interrupted := runError == context.Canceled
+	if runError == context.DeadlineExceeded {
+		handleWarning(fmt.Sprintf("Reached maxDuration of %s, remaining links were not checked", *maxDuration))
+	} else if interrupted {
+		handleWarning("Interrupted, remaining links were not checked")
+	} else if runError != nil {
+		handleFatal(runError)
+	}
+	if runError != nil && *checkpoint != "" {
+		logs.info(fmt.Sprintf("Saved the crawl to %s, run again with the same -checkpoint to resume it", *checkpoint))
+	}
+
+	if closeError := writer.close(); closeError != nil {
+		handleFatal(closeError)
+	}
+
+	if policy.known > 0 {
+		logs.info(fmt.Sprintf("Ignored %d broken links listed in the baseline", policy.known))
+	}
+	if policy.baseline != nil {
+		for _, fixed := range policy.baseline.fixedURLs() {
+			handleWarning(fmt.Sprintf("%s is listed in the baseline but no longer broken, it can be removed", fixed))
+		}
+	}
+	if policy.failed() {
+		fmt.Fprintln(os.Stderr, aurora.Red(fmt.Sprintf("Found %d broken links, more than the %d allowed", policy.broken, policy.maxBroken)))
+		os.Exit(EXIT_CODE_BROKEN_LINKS)
+	}
+	if interrupted {
+		os.Exit(EXIT_CODE_INTERRUPTED)
 	}
-	collector.Wait()
 }
 
-// Represents a requested link containing the url and status derived from the requests response.
-type Link struct {
-	status int
-	url    *url.URL
+// Prints a crawl result as a link status, an error or a warning
+func printResult(result linkhealth.Result, reportCertExpiry bool) {
+	if result.IsWarning() {
+		fmt.Println(getSeverityLabel(result.Severity), result.Warning)
+		return
+	}
+
+	if result.Err != nil {
+		fmt.Println(getSeverityLabel(result.Severity), fmt.Sprintf("Request to %s failed (%s). Reason: %s%s%s", result.URL, result.Category(), getFailureReason(result), getArchivedAt(result.ArchivedURL), getLinkedFrom(result.Parents)))
+		return
+	}
+
+	printLinkStatus(&result.Link, reportCertExpiry)
 }
 
-// Checks whether the link was healthy by using the link status
-func (link *Link) isHealthy() bool {
-	return link.status >= DEFAULT_HEALTHY_HTTP_MIN_STATUS_CODE && link.status <= DEFAULT_HEALTHY_HTTP_MAX_STATUS_CODE
+// Labels results by their severity, broken links with a lower severity than error do not fail the run
+func getSeverityLabel(severity string) aurora.Value {
+	switch severity {
+	case linkhealth.SEVERITY_WARNING:
+		return aurora.Yellow("Warning:")
+	case linkhealth.SEVERITY_INFO:
+		return aurora.Cyan("Info:")
+	default:
+		return aurora.Red("Error:")
+	}
+}
+
+// Returns the reason a request failed, or its status when a response was received
+func getFailureReason(result linkhealth.Result) string {
+	if result.Err == nil {
+		return fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status))
+	}
+
+	reason := result.Err.Error()
+	if reason == "" {
+		reason = "Unknown"
+	}
+	return reason
+}
+
+// Formats the archived snapshot of a dead link for the end of an output line
+func getArchivedAt(archivedURL string) string {
+	if archivedURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("	archived at %s", archivedURL)
+}
+
+// Formats the pages a link was found on for the end of an output line
+func getLinkedFrom(parents []string) string {
+	if len(parents) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("	linked from %s", strings.Join(parents, ", "))
 }
 
 // Prints the link status, and formats the output color based on link health
-func (link *Link) printLinkStatus(isHealthy bool) {
-	if isHealthy {
+func printLinkStatus(link *linkhealth.Link, reportCertExpiry bool) {
+	details := ""
+	if reportCertExpiry && link.HasCertificate {
+		details = fmt.Sprintf("	cert expires in %d days", link.CertExpiryDays)
+	}
+	if len(link.Redirects) > 0 {
+		details += fmt.Sprintf("	redirects %s", linkhealth.FormatRedirects(link.Redirects))
+	}
+	details += getArchivedAt(link.ArchivedURL)
+
+	if link.Slow {
 		fmt.Printf(
-			"%s	%s\n",
-			link.url,
+			"%s	%s	%s%s\n",
+			link.URL,
+			aurora.Yellow("slow"),
+			link.Latency.Round(time.Millisecond),
+			details,
+		)
+	} else if link.IsHealthy() {
+		fmt.Printf(
+			"%s	%s%s\n",
+			link.URL,
 			aurora.Green("healthy"),
+			details,
 		)
 	} else {
 		fmt.Printf(
-			"%s	%s	%d\n",
-			link.url,
+			"%s	%s	%d%s%s\n",
+			link.URL,
 			aurora.Red("down"),
-			aurora.Bold(link.status),
+			aurora.Bold(link.Status),
+			details,
+			getLinkedFrom(link.Parents),
 		)
 	}
 }
 
+// Prints the latency percentiles and error rate of a benchmark
+func printBenchmarkResult(benchmarkURL *url.URL, result *linkhealth.BenchmarkResult) {
+	total := len(result.Latencies)
+	fmt.Printf("%s	%d requests in %s\n", benchmarkURL, total, result.Duration.Round(time.Millisecond))
+	if total == 0 {
+		return
+	}
+
+	fmt.Printf(
+		"min %s	p50 %s	p90 %s	p99 %s	max %s\n",
+		result.Latencies[0].Round(time.Millisecond),
+		result.Percentile(50).Round(time.Millisecond),
+		result.Percentile(90).Round(time.Millisecond),
+		result.Percentile(99).Round(time.Millisecond),
+		result.Latencies[total-1].Round(time.Millisecond),
+	)
+
+	errorRate := fmt.Sprintf("%d errors (%.1f%%)", result.Errors, result.ErrorRate())
+	if result.Errors > 0 {
+		fmt.Println(aurora.Red(errorRate))
+	} else {
+		fmt.Println(aurora.Green(errorRate))
+	}
+}
+
 // Attempts to parse the provided URL, returns an instance of URL if it is valid otherwise returns null
 func getURL(targetURL string) (*url.URL, error) {
 	if !isValidURL(targetURL) {
@@ -90,70 +685,37 @@ func isValidURL(toTest string) bool {
 	return true
 }
 
-// Initializes a new collector instance
-func getCollector(userAgent string, depth int, threads int) *colly.Collector {
-	collector := colly.NewCollector(
-		colly.Async(true),
-		colly.UserAgent(userAgent),
-		colly.MaxDepth(depth),
-		colly.URLFilters(
-			regexp.MustCompile("https?://.+$"),
-		),
-	)
-
-	limitError := collector.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: threads,
-		RandomDelay: 1 * time.Second,
-	})
-
-	if limitError != nil {
-		handleError(limitError)
+func handleError(error error) {
+	if error != nil {
+		logs.log(LOG_LEVEL_ERROR, error.Error())
 	}
-
-	// On error print the reason the request failed
-	collector.OnError(func(response *colly.Response, err error) {
-		url := response.Request.URL
-		reason := err.Error()
-
-		if reason == "" {
-			reason = "Unknown"
-		}
-
-		handleError(fmt.Errorf("Request to %s failed. Reason: %s", url, reason))
-	})
-
-	collector.OnHTML("a[href]", func(element *colly.HTMLElement) {
-		link := element.Attr("href")
-		_ = element.Request.Visit(link)
-	})
-
-	collector.OnResponse(func(response *colly.Response) {
-		link := Link{
-			url:    response.Request.URL,
-			status: response.StatusCode,
-		}
-
-		if !link.isHealthy() {
-			link.printLinkStatus(false)
-			return
-		}
-
-		link.printLinkStatus(true)
-	})
-
-	return collector
 }
 
-func handleError(error error) {
-	if error != nil {
-		fmt.Println(aurora.Red("Error:"), error)
+func handleWarning(warning string) {
+	if warning != "" {
+		logs.log(LOG_LEVEL_WARNING, warning)
 	}
 }
 
 func handleFatal(error error) {
 	if error != nil {
-		fmt.Println(aurora.BrightRed("Fatal:"), error)
+		logs.log(LOG_LEVEL_FATAL, error.Error())
 		os.Exit(1)
 	}
 }
+
+// The checkTLS threshold takes precedence over certExpiryWarn, so the certificates it highlights are also warned about
+func getCertExpiryWarn(certExpiryWarn int, checkTLS int) int {
+	if checkTLS > 0 {
+		return checkTLS
+	}
+	return certExpiryWarn
+}
+
+// Returns the parameters to strip from links, including the tracking parameters when requested
+func getStripParams(params []string, tracking bool) []string {
+	if tracking {
+		return append(append([]string(nil), params...), linkhealth.TRACKING_PARAMS...)
+	}
+	return params
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Path the metrics are served on in watch mode
+const METRICS_PATH = "/metrics"
+
+// Metrics of watch mode in the Prometheus text format. Counters accumulate over every crawl, gauges describe
+// the latest finished crawl so scrapes never see a crawl in progress.
+type watchMetrics struct {
+	lock    sync.Mutex
+	checked int
+	crawls  int
+	latest  *crawlMetrics
+	current *crawlMetrics
+}
+
+// Statistics of a single crawl
+type crawlMetrics struct {
+	broken      int
+	statusCodes map[int]int
+	hostChecked map[string]int
+	hostBroken  map[string]int
+	duration    time.Duration
+	finished    time.Time
+}
+
+func newCrawlMetrics() *crawlMetrics {
+	return &crawlMetrics{
+		statusCodes: make(map[int]int),
+		hostChecked: make(map[string]int),
+		hostBroken:  make(map[string]int),
+	}
+}
+
+func newWatchMetrics() *watchMetrics {
+	return &watchMetrics{current: newCrawlMetrics()}
+}
+
+func (metrics *watchMetrics) record(result linkhealth.Result) {
+	if result.IsWarning() || result.URL == nil {
+		return
+	}
+
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.checked++
+	metrics.current.statusCodes[result.Status]++
+	host := result.URL.Hostname()
+	metrics.current.hostChecked[host]++
+	if result.Err != nil || !result.IsHealthy() {
+		metrics.current.broken++
+		metrics.current.hostBroken[host]++
+	}
+}
+
+// Publishes the statistics of the crawl that just finished and starts collecting the next one
+func (metrics *watchMetrics) finish(duration time.Duration) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.crawls++
+	metrics.current.duration = duration
+	metrics.current.finished = time.Now()
+	metrics.latest = metrics.current
+	metrics.current = newCrawlMetrics()
+}
+
+func (metrics *watchMetrics) ServeHTTP(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.write(response)
+}
+
+func (metrics *watchMetrics) write(output io.Writer) {
+	writeMetric(output, "links_checked_total", "counter", "Links checked over every crawl", metrics.checked)
+	writeMetric(output, "crawls_total", "counter", "Crawls finished", metrics.crawls)
+	if metrics.latest == nil {
+		return
+	}
+
+	latest := metrics.latest
+	writeMetric(output, "links_broken", "gauge", "Broken links found by the latest crawl", latest.broken)
+	writeMetric(output, "crawl_duration_seconds", "gauge", "Duration of the latest crawl", latest.duration.Seconds())
+	writeMetric(output, "crawl_finished_timestamp_seconds", "gauge", "Time the latest crawl finished", latest.finished.Unix())
+
+	writeMetricHeader(output, "links_status_code", "gauge", "Links checked by the latest crawl per response status code, 0 for requests without a response")
+	codes := make([]int, 0, len(latest.statusCodes))
+	for code := range latest.statusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(output, "links_status_code{code=\"%d\"} %d\n", code, latest.statusCodes[code])
+	}
+
+	hosts := make([]string, 0, len(latest.hostChecked))
+	for host := range latest.hostChecked {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	writeMetricHeader(output, "host_failure_rate", "gauge", "Share of the links checked per host by the latest crawl that were broken")
+	for _, host := range hosts {
+		fmt.Fprintf(output, "host_failure_rate{host=%q} %g\n", host, float64(latest.hostBroken[host])/float64(latest.hostChecked[host]))
+	}
+}
+
+func writeMetricHeader(output io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(output, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetric(output io.Writer, name string, kind string, help string, value interface{}) {
+	writeMetricHeader(output, name, kind, help)
+	fmt.Fprintf(output, "%s %v\n", name, value)
+}
+
+// Serves the metrics in the background, exiting when the address cannot be listened on
+func serveMetrics(address string, metrics *watchMetrics) {
+	mux := http.NewServeMux()
+	mux.Handle(METRICS_PATH, metrics)
+	go func() {
+		if serveError := http.ListenAndServe(address, mux); serveError != nil {
+			handleFatal(fmt.Errorf("Could not serve metrics on %s: %s", address, serveError))
+		}
+	}()
+}
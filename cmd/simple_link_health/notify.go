@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+const (
+	// Events notifications are sent for
+	NOTIFY_EVENT_BROKEN = "broken"
+	NOTIFY_EVENT_DOWN   = "down"
+
+	NOTIFY_TIMEOUT = 10 * time.Second
+	SLACK_API_URL  = "https://slack.com/api/chat.postMessage"
+	// Links listed in a Slack message, the rest are only counted
+	SLACK_MAX_LINKS = 20
+)
+
+// Broken links reported by a notification
+type notification struct {
+	Event     string         `json:"event"`
+	Text      string         `json:"text"`
+	Links     []notifiedLink `json:"links"`
+	Timestamp time.Time      `json:"timestamp"`
+	// Counts of the finished crawl, not set for links going down while watching
+	Summary *linkhealth.Summary `json:"-"`
+}
+
+type notifiedLink struct {
+	URL     string   `json:"url"`
+	Status  int      `json:"status,omitempty"`
+	Reason  string   `json:"reason"`
+	Parents []string `json:"parents,omitempty"`
+}
+
+func newNotification(event string, links []notifiedLink) notification {
+	text := fmt.Sprintf("Found %d broken links", len(links))
+	if event == NOTIFY_EVENT_DOWN {
+		text = fmt.Sprintf("%d links went down", len(links))
+	}
+	return notification{Event: event, Text: text, Links: links, Timestamp: time.Now()}
+}
+
+// Sends notifications to a destination such as a webhook or a Slack channel
+type notifier interface {
+	notify(message notification) error
+}
+
+// Sends a notification to every notifier, reporting the ones that failed
+type notifiers []notifier
+
+func (destinations notifiers) notify(message notification) {
+	for _, destination := range destinations {
+		if notifyError := destination.notify(message); notifyError != nil {
+			handleError(notifyError)
+		}
+	}
+}
+
+// Posts notifications as JSON to a URL
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (webhook *webhookNotifier) notify(message notification) error {
+	body, encodeError := json.Marshal(message)
+	if encodeError != nil {
+		return encodeError
+	}
+
+	response, postError := webhook.client.Post(webhook.url, "application/json", bytes.NewReader(body))
+	if postError != nil {
+		return fmt.Errorf("Could not notify webhook: %s", postError)
+	}
+	defer response.Body.Close()
+	_, _ = ioutil.ReadAll(response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Could not notify webhook: %s responded with %d %s", webhook.url, response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	return nil
+}
+
+// Posts notifications to a Slack channel with a bot token
+type slackNotifier struct {
+	token   string
+	channel string
+	client  *http.Client
+}
+
+// Parses the value of the notifySlack flag, given as token/channel
+func newSlackNotifier(value string, client *http.Client) (*slackNotifier, error) {
+	separator := strings.LastIndex(value, "/")
+	if separator <= 0 || separator == len(value)-1 {
+		return nil, fmt.Errorf("Invalid notifySlack value, expected token/channel")
+	}
+	return &slackNotifier{token: value[:separator], channel: value[separator+1:], client: client}, nil
+}
+
+func (slack *slackNotifier) notify(message notification) error {
+	body, encodeError := json.Marshal(map[string]string{
+		"channel": slack.channel,
+		"text":    formatSlackMessage(message),
+	})
+	if encodeError != nil {
+		return encodeError
+	}
+
+	request, requestError := http.NewRequest("POST", SLACK_API_URL, bytes.NewReader(body))
+	if requestError != nil {
+		return requestError
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	request.Header.Set("Authorization", "Bearer "+slack.token)
+
+	response, postError := slack.client.Do(request)
+	if postError != nil {
+		return fmt.Errorf("Could not notify Slack: %s", postError)
+	}
+	defer response.Body.Close()
+
+	// Slack responds with 200 and reports failures in the body
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if decodeError := json.NewDecoder(response.Body).Decode(&result); decodeError != nil {
+		return fmt.Errorf("Could not notify Slack: %d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	if !result.OK {
+		return fmt.Errorf("Could not notify Slack: %s", result.Error)
+	}
+	return nil
+}
+
+func formatSlackMessage(message notification) string {
+	lines := []string{"*" + message.Text + "*"}
+	for index, link := range message.Links {
+		if index == SLACK_MAX_LINKS {
+			lines = append(lines, fmt.Sprintf("and %d more", len(message.Links)-SLACK_MAX_LINKS))
+			break
+		}
+		line := fmt.Sprintf("• %s %s", link.URL, link.Reason)
+		if len(link.Parents) > 0 {
+			line += ", linked from " + strings.Join(link.Parents, ", ")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Creates the notifiers of the notifyWebhook and notifySlack flags
+func getNotifiers(webhookURL string, slack string) (notifiers, error) {
+	client := &http.Client{Timeout: NOTIFY_TIMEOUT}
+	var destinations notifiers
+	if webhookURL != "" {
+		destinations = append(destinations, &webhookNotifier{url: webhookURL, client: client})
+	}
+	if slack != "" {
+		slackDestination, slackError := newSlackNotifier(slack, client)
+		if slackError != nil {
+			return nil, slackError
+		}
+		destinations = append(destinations, slackDestination)
+	}
+	return destinations, nil
+}
+
+// Sends a notification listing the broken links once the crawl finishes, when any were found
+type notifyWriter struct {
+	destinations notifiers
+	checker      *linkhealth.Checker
+	summary      *linkhealth.Summary
+	broken       []linkhealth.Result
+}
+
+func (writer *notifyWriter) write(result linkhealth.Result) {
+	writer.summary.Add(result)
+	if !result.IsWarning() && (result.Err != nil || !result.IsHealthy()) {
+		writer.broken = append(writer.broken, result)
+	}
+}
+
+func (writer *notifyWriter) close() error {
+	if len(writer.broken) == 0 {
+		return nil
+	}
+
+	links := make([]notifiedLink, 0, len(writer.broken))
+	for _, result := range writer.broken {
+		link := notifiedLink{Status: result.Status, Reason: getFailureReason(result)}
+		if result.URL != nil {
+			link.URL = result.URL.String()
+			link.Parents = writer.checker.LinkedFrom(result.URL)
+		}
+		links = append(links, link)
+	}
+	writer.summary.Finish()
+	message := newNotification(NOTIFY_EVENT_BROKEN, links)
+	message.Summary = writer.summary
+	writer.destinations.notify(message)
+	return nil
+}
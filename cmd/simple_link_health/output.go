@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	OUTPUT_TEXT   = "text"
+	OUTPUT_JSON   = "json"
+	OUTPUT_NDJSON = "ndjson"
+	OUTPUT_JUNIT  = "junit"
+	OUTPUT_CSV    = "csv"
+	OUTPUT_GITHUB = "github"
+)
+
+// Writes crawl results in one of the supported output formats
+type resultWriter interface {
+	write(result linkhealth.Result)
+	// Called once the crawl has finished
+	close() error
+}
+
+// Creates the result writer for the output format, the checker is used to look up every page a link was found on
+func getResultWriter(format string, reportCertExpiry bool, checkTLS int, summaryOnly bool, checker *linkhealth.Checker) (resultWriter, error) {
+	switch format {
+	case OUTPUT_TEXT:
+		return &textWriter{
+			reportCertExpiry: reportCertExpiry,
+			checkTLS:         checkTLS,
+			summaryOnly:      summaryOnly,
+			checker:          checker,
+			summary:          linkhealth.NewSummary(),
+		}, nil
+	case OUTPUT_JSON:
+		return &jsonWriter{output: os.Stdout, checker: checker}, nil
+	case OUTPUT_NDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(os.Stdout)}, nil
+	case OUTPUT_JUNIT:
+		return &junitWriter{output: os.Stdout, checker: checker, started: time.Now()}, nil
+	case OUTPUT_CSV:
+		return &csvWriter{output: os.Stdout, checker: checker}, nil
+	case OUTPUT_GITHUB:
+		return &githubWriter{output: os.Stdout, checker: checker}, nil
+	default:
+		return nil, fmt.Errorf("Unknown output format %q, expected one of text, json, ndjson, junit, csv, github", format)
+	}
+}
+
+// Prints colored results as they arrive, followed by every broken link with the pages linking to it
+// and a summary of the crawl. Only the summary is printed in summary only mode.
+type textWriter struct {
+	reportCertExpiry bool
+	// Certificates of every HTTPS host are listed when positive, highlighting those expiring within this many days
+	checkTLS    int
+	summaryOnly bool
+	checker     *linkhealth.Checker
+	broken      []linkhealth.Result
+	summary     *linkhealth.Summary
+}
+
+func (writer *textWriter) write(result linkhealth.Result) {
+	writer.summary.Add(result)
+	if writer.summaryOnly {
+		return
+	}
+
+	printResult(result, writer.reportCertExpiry)
+
+	if !result.IsWarning() && (result.Err != nil || !result.IsHealthy()) {
+		writer.broken = append(writer.broken, result)
+	}
+}
+
+func (writer *textWriter) close() error {
+	writer.printBrokenLinks()
+	writer.printCertificates()
+	writer.summary.Finish()
+	printSummary(writer.summary)
+	return nil
+}
+
+func (writer *textWriter) printBrokenLinks() {
+	if len(writer.broken) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(aurora.Bold("Broken links"))
+	for _, result := range writer.broken {
+		fmt.Printf("%s	%s	%s\n", result.URL, aurora.Red(result.Category()), aurora.Red(getFailureReason(result)))
+		if result.ArchivedURL != "" {
+			fmt.Printf("	archived at %s\n", result.ArchivedURL)
+		}
+		if len(result.CrawlPath) > 1 {
+			fmt.Printf("	found at depth %d via %s\n", result.Depth, strings.Join(result.CrawlPath, " → "))
+		}
+		for _, parent := range writer.checker.LinkedFrom(result.URL) {
+			fmt.Printf("	linked from %s\n", parent)
+		}
+	}
+}
+
+func (writer *textWriter) printCertificates() {
+	if writer.checkTLS <= 0 || len(writer.summary.Certificates) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(aurora.Bold("Certificates"))
+	for _, host := range writer.summary.SortedCertificateHosts() {
+		days := writer.summary.Certificates[host]
+		if days <= writer.checkTLS {
+			fmt.Printf("%s	%s\n", host, aurora.Yellow(fmt.Sprintf("expires in %d days", days)))
+		} else {
+			fmt.Printf("%s	expires in %d days\n", host, days)
+		}
+	}
+}
+
+// Prints the link counts, status code breakdown, crawl duration and slowest links
+func printSummary(summary *linkhealth.Summary) {
+	fmt.Println()
+	fmt.Println(aurora.Bold("Summary"))
+	fmt.Printf("Checked	%d links in %s\n", summary.Checked, summary.Duration.Round(time.Millisecond))
+	fmt.Printf("Healthy	%d\n", aurora.Green(summary.Healthy))
+	if summary.Broken > 0 {
+		fmt.Printf("Broken	%d\n", aurora.Red(summary.Broken))
+	} else {
+		fmt.Printf("Broken	%d\n", summary.Broken)
+	}
+	if summary.Slow > 0 {
+		fmt.Printf("Slow	%d\n", aurora.Yellow(summary.Slow))
+	}
+	if summary.Warnings > 0 {
+		fmt.Printf("Warnings	%d\n", aurora.Yellow(summary.Warnings))
+	}
+
+	for _, code := range summary.SortedStatusCodes() {
+		if code == 0 {
+			fmt.Printf("	no response	%d\n", summary.StatusCodes[code])
+		} else {
+			fmt.Printf("	%d %s	%d\n", code, http.StatusText(code), summary.StatusCodes[code])
+		}
+	}
+	if len(summary.Categories) > 0 {
+		fmt.Println("Broken by category")
+		for _, category := range summary.SortedCategories() {
+			fmt.Printf("	%s	%d\n", category, summary.Categories[category])
+		}
+	}
+
+	if len(summary.Slowest) > 0 {
+		fmt.Println("Slowest")
+		for _, link := range summary.Slowest {
+			fmt.Printf("	%s	first byte %s	%s\n", link.Latency.Round(time.Millisecond), link.TimeToFirstByte.Round(time.Millisecond), link.URL)
+		}
+	}
+}
+
+// Structured representation of a result used by the JSON output formats
+type jsonResult struct {
+	URL            string         `json:"url"`
+	Status         int            `json:"status,omitempty"`
+	Healthy        bool           `json:"healthy"`
+	Parents        []string       `json:"parents,omitempty"`
+	Depth          int            `json:"depth,omitempty"`
+	CrawlPath      []string       `json:"crawlPath,omitempty"`
+	LatencyMs      int64          `json:"latencyMs"`
+	TTFBMs         int64          `json:"ttfbMs"`
+	Slow           bool           `json:"slow,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Category       string         `json:"category,omitempty"`
+	ArchivedURL    string         `json:"archivedUrl,omitempty"`
+	Warning        string         `json:"warning,omitempty"`
+	Severity       string         `json:"severity,omitempty"`
+	CertExpiryDays *int           `json:"certExpiryDays,omitempty"`
+	Size           *int           `json:"size,omitempty"`
+	Redirects      []jsonRedirect `json:"redirects,omitempty"`
+	Timestamp      time.Time      `json:"timestamp"`
+}
+
+type jsonRedirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+func newJSONResult(result linkhealth.Result) jsonResult {
+	structured := jsonResult{
+		Status:      result.Status,
+		Healthy:     result.Err == nil && !result.IsWarning() && result.IsHealthy(),
+		Parents:     result.Parents,
+		Depth:       result.Depth,
+		LatencyMs:   result.Latency.Milliseconds(),
+		TTFBMs:      result.TimeToFirstByte.Milliseconds(),
+		Slow:        result.Slow,
+		Category:    result.Category(),
+		ArchivedURL: result.ArchivedURL,
+		Warning:     result.Warning,
+		Severity:    result.Severity,
+		Timestamp:   result.CheckedAt,
+	}
+
+	if result.URL != nil {
+		structured.URL = result.URL.String()
+	}
+	if result.Err != nil {
+		structured.Error = result.Err.Error()
+	}
+	// The path is only included for broken links, to tell how deep they are buried
+	if !structured.Healthy && !result.IsWarning() {
+		structured.CrawlPath = result.CrawlPath
+	}
+	for _, redirect := range result.Redirects {
+		structured.Redirects = append(structured.Redirects, jsonRedirect{URL: redirect.URL, Status: redirect.Status, Location: redirect.Location})
+	}
+	if result.HasSize {
+		size := result.Size
+		structured.Size = &size
+	}
+	if result.HasCertificate {
+		certExpiryDays := result.CertExpiryDays
+		structured.CertExpiryDays = &certExpiryDays
+	}
+
+	return structured
+}
+
+// Collects every result and writes them as a single JSON array once the crawl finishes,
+// including every page each link was found on
+type jsonWriter struct {
+	output  io.Writer
+	checker *linkhealth.Checker
+	results []linkhealth.Result
+}
+
+func (writer *jsonWriter) write(result linkhealth.Result) {
+	writer.results = append(writer.results, result)
+}
+
+func (writer *jsonWriter) close() error {
+	structured := []jsonResult{}
+	for _, result := range writer.results {
+		if !result.IsWarning() && result.URL != nil {
+			result.Parents = writer.checker.LinkedFrom(result.URL)
+		}
+		structured = append(structured, newJSONResult(result))
+	}
+
+	encoder := json.NewEncoder(writer.output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(structured)
+}
+
+// Streams each result as a JSON object on its own line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (writer *ndjsonWriter) write(result linkhealth.Result) {
+	if encodeError := writer.encoder.Encode(newJSONResult(result)); encodeError != nil {
+		fmt.Fprintln(os.Stderr, encodeError)
+	}
+}
+
+func (writer *ndjsonWriter) close() error {
+	return nil
+}
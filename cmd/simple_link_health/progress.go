@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+const (
+	// How often the live progress line is redrawn on a terminal
+	PROGRESS_REFRESH_INTERVAL = 200 * time.Millisecond
+	// How often a progress line is logged when stderr is not a terminal
+	PROGRESS_LOG_INTERVAL = 10 * time.Second
+)
+
+// Shows the progress of the crawl on stderr. On a terminal a single line is updated in place, cleared while other
+// output is written, otherwise a progress line is logged every PROGRESS_LOG_INTERVAL.
+type progressWriter struct {
+	writer  resultWriter
+	checker *linkhealth.Checker
+	live    bool
+	lock    sync.Mutex
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newProgressWriter(writer resultWriter, checker *linkhealth.Checker) *progressWriter {
+	progress := &progressWriter{writer: writer, checker: checker, live: isStderrTerminal(), stop: make(chan struct{})}
+	interval := PROGRESS_LOG_INTERVAL
+	if progress.live {
+		interval = PROGRESS_REFRESH_INTERVAL
+	}
+
+	progress.stopped.Add(1)
+	go func() {
+		defer progress.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progress.lock.Lock()
+				progress.draw()
+				progress.lock.Unlock()
+			case <-progress.stop:
+				return
+			}
+		}
+	}()
+	return progress
+}
+
+func (progress *progressWriter) write(result linkhealth.Result) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	progress.clear()
+	progress.writer.write(result)
+	if progress.live {
+		progress.draw()
+	}
+}
+
+func (progress *progressWriter) close() error {
+	close(progress.stop)
+	progress.stopped.Wait()
+	progress.clear()
+	return progress.writer.close()
+}
+
+func (progress *progressWriter) draw() {
+	line := formatProgress(progress.checker.Progress())
+	if progress.live {
+		fmt.Fprintf(os.Stderr, "\r\x1b[2K%s", line)
+	} else {
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+func (progress *progressWriter) clear() {
+	if progress.live {
+		fmt.Fprint(os.Stderr, "\r\x1b[2K")
+	}
+}
+
+// Formats the counters of the crawl, e.g. "Checked 120 links, 34 queued, 3 broken, 12.5 requests/s, 1m5s elapsed"
+func formatProgress(progress linkhealth.Progress) string {
+	return fmt.Sprintf(
+		"Checked %d links, %d queued, %d broken, %.1f requests/s, %s elapsed",
+		progress.Checked,
+		progress.Queued(),
+		progress.Broken,
+		progress.RequestsPerSecond(),
+		time.Since(progress.Started).Round(time.Second),
+	)
+}
+
+// Checks whether stderr is a terminal the progress line can be updated in place on
+func isStderrTerminal() bool {
+	info, statError := os.Stderr.Stat()
+	if statError != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
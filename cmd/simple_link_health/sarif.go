@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Output format of the files subcommand for code scanning tools such as GitHub code scanning
+const OUTPUT_SARIF = "sarif"
+
+const (
+	SARIF_VERSION = "2.1.0"
+	SARIF_SCHEMA  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// Rules of broken remote URLs and of links to missing local files
+	SARIF_RULE_BROKEN_URL   = "broken-url"
+	SARIF_RULE_MISSING_FILE = "missing-file"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// Writes the broken links of the files subcommand as a SARIF log, with each link located at its file and
+// line, so code scanning shows them as annotations. Files are located relative to the working directory,
+// which is expected to be the root of the repository.
+func writeSarif(output io.Writer, broken []linkhealth.FileLinkResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: JUNIT_SUITE_NAME,
+			Rules: []sarifRule{
+				{ID: SARIF_RULE_BROKEN_URL, ShortDescription: sarifMessage{Text: "Links to remote URLs must not be broken"}},
+				{ID: SARIF_RULE_MISSING_FILE, ShortDescription: sarifMessage{Text: "Links to local files must point to an existing file"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, result := range broken {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  getSarifRule(result),
+			Level:   "error",
+			Message: sarifMessage{Text: "Broken link " + result.Target + ": " + result.Err.Error()},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: (&url.URL{Path: getRepositoryPath(result.File)}).String(), URIBaseID: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: result.Line},
+			}}},
+		})
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: SARIF_SCHEMA, Version: SARIF_VERSION, Runs: []sarifRun{run}})
+}
+
+func getSarifRule(result linkhealth.FileLinkResult) string {
+	if target, parseError := url.Parse(result.Target); parseError == nil && (target.Scheme == "http" || target.Scheme == "https") {
+		return SARIF_RULE_BROKEN_URL
+	}
+	return SARIF_RULE_MISSING_FILE
+}
+
+// Returns the path of the file relative to the working directory, with forward slashes
+func getRepositoryPath(file string) string {
+	if filepath.IsAbs(file) {
+		if workingDirectory, wdError := os.Getwd(); wdError == nil {
+			if relative, relError := filepath.Rel(workingDirectory, file); relError == nil && !strings.HasPrefix(relative, "..") {
+				file = relative
+			}
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(file), "./")
+}
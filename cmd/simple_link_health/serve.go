@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+)
+
+// Subcommand serving an HTTP API to run crawls on
+const SERVE_COMMAND = "serve"
+
+const (
+	// The API crawls any URL it is given, so it only listens on the loopback interface unless told otherwise
+	DEFAULT_SERVE_ADDRESS = "localhost:8080"
+	DEFAULT_MAX_SCANS     = 4
+	// Environment variable holding the bearer token of the API when it is not given as a flag
+	SERVE_TOKEN_VARIABLE = "SERVE_TOKEN"
+	// Largest scan request body accepted
+	MAX_SCAN_REQUEST_SIZE = 1024 * 1024
+	SCANS_PATH            = "/scans"
+)
+
+// States of a scan
+const (
+	SCAN_RUNNING   = "running"
+	SCAN_FINISHED  = "finished"
+	SCAN_CANCELLED = "cancelled"
+	SCAN_FAILED    = "failed"
+)
+
+// Options of a crawl started through the API, named like the flags they correspond to
+type scanRequest struct {
+	URLs              []string `json:"urls"`
+	UserAgent         string   `json:"userAgent"`
+	Depth             *int     `json:"depth"`
+	Threads           int      `json:"threads"`
+	External          string   `json:"external"`
+	ExternalThreads   int      `json:"externalThreads"`
+	AllowedDomains    []string `json:"allowedDomains"`
+	DisallowedDomains []string `json:"disallowedDomains"`
+	Include           []string `json:"include"`
+	Exclude           []string `json:"exclude"`
+	Header            []string `json:"header"`
+	HostHeader        []string `json:"hostHeader"`
+	BasicAuth         string   `json:"basicAuth"`
+	CheckAssets       bool     `json:"checkAssets"`
+	CheckFragments    bool     `json:"checkFragments"`
+	CheckCanonical    bool     `json:"checkCanonical"`
+	FollowMetaRefresh bool     `json:"followMetaRefresh"`
+	CheckHreflang     bool     `json:"checkHreflang"`
+	CheckSchemes      bool     `json:"checkSchemes"`
+	Sitemap           bool     `json:"sitemap"`
+	SitemapOnly       bool     `json:"sitemapOnly"`
+	StripQuery        bool     `json:"stripQuery"`
+	StripParam        []string `json:"stripParam"`
+	StripTracking     bool     `json:"stripTracking"`
+	SortQuery         bool     `json:"sortQuery"`
+	ExpectText        []string `json:"expectText"`
+	RejectText        []string `json:"rejectText"`
+	MinContentLength  int      `json:"minContentLength"`
+	MinSize           int      `json:"minSize"`
+	ContentType       []string `json:"contentType"`
+	HealthyCodes      string   `json:"healthyCodes"`
+	WarningCodes      string   `json:"warningCodes"`
+	Severity          []string `json:"severity"`
+	MaxRedirects      int      `json:"maxRedirects"`
+	IgnoreRobots      bool     `json:"ignoreRobots"`
+	HeadFirst         bool     `json:"headFirst"`
+	Timeout           string   `json:"timeout"`
+	Retries           int      `json:"retries"`
+	SlowThreshold     string   `json:"slowThreshold"`
+	MaxLinks          int      `json:"maxLinks"`
+	MaxPages          int      `json:"maxPages"`
+	MaxDuration       string   `json:"maxDuration"`
+}
+
+// Converts the request into the options of its crawl, along with the longest time the crawl may take
+func (request *scanRequest) options() (linkhealth.Options, time.Duration, error) {
+	if len(request.URLs) == 0 {
+		return linkhealth.Options{}, 0, fmt.Errorf("Missing urls to start from")
+	}
+	var targetURLs []*url.URL
+	for _, targetURL := range request.URLs {
+		parsed, urlError := getURL(targetURL)
+		if urlError != nil {
+			return linkhealth.Options{}, 0, fmt.Errorf("Invalid URL %q", targetURL)
+		}
+		targetURLs = append(targetURLs, parsed)
+	}
+
+	depth := linkhealth.DEFAULT_DEPTH
+	if request.Depth != nil {
+		depth = *request.Depth
+	}
+	durations := make(map[string]time.Duration)
+	for name, value := range map[string]string{"timeout": request.Timeout, "slowThreshold": request.SlowThreshold, "maxDuration": request.MaxDuration} {
+		if value == "" {
+			continue
+		}
+		duration, durationError := time.ParseDuration(value)
+		if durationError != nil {
+			return linkhealth.Options{}, 0, fmt.Errorf("Invalid %s %q, expected a duration such as 10s", name, value)
+		}
+		durations[name] = duration
+	}
+
+	include, includeError := linkhealth.CompileURLPatterns(request.Include)
+	if includeError != nil {
+		return linkhealth.Options{}, 0, includeError
+	}
+	exclude, excludeError := linkhealth.CompileURLPatterns(request.Exclude)
+	if excludeError != nil {
+		return linkhealth.Options{}, 0, excludeError
+	}
+	expectText, expectError := linkhealth.CompileTextPatterns(request.ExpectText)
+	if expectError != nil {
+		return linkhealth.Options{}, 0, expectError
+	}
+	rejectText, rejectError := linkhealth.CompileTextPatterns(request.RejectText)
+	if rejectError != nil {
+		return linkhealth.Options{}, 0, rejectError
+	}
+	healthyCodes, healthyError := linkhealth.ParseStatusCodes(request.HealthyCodes)
+	if healthyError != nil {
+		return linkhealth.Options{}, 0, healthyError
+	}
+	warningCodes, warningError := linkhealth.ParseStatusCodes(request.WarningCodes)
+	if warningError != nil {
+		return linkhealth.Options{}, 0, warningError
+	}
+	severities, severityError := linkhealth.ParseSeverityRules(request.Severity)
+	if severityError != nil {
+		return linkhealth.Options{}, 0, severityError
+	}
+	headers, headersError := parseHeaders(request.Header)
+	if headersError != nil {
+		return linkhealth.Options{}, 0, headersError
+	}
+	if request.BasicAuth != "" {
+		if !strings.Contains(request.BasicAuth, ":") {
+			return linkhealth.Options{}, 0, fmt.Errorf("Invalid basicAuth value, expected user:password")
+		}
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(request.BasicAuth))
+	}
+	hostRules, hostHeadersError := parseHostHeaders(request.HostHeader)
+	if hostHeadersError != nil {
+		return linkhealth.Options{}, 0, hostHeadersError
+	}
+
+	options := linkhealth.Options{
+		URLs:              targetURLs,
+		UserAgent:         request.UserAgent,
+		Depth:             depth,
+		Threads:           request.Threads,
+		External:          request.External,
+		ExternalThreads:   request.ExternalThreads,
+		AllowedDomains:    request.AllowedDomains,
+		DisallowedDomains: request.DisallowedDomains,
+		Include:           include,
+		Exclude:           exclude,
+		HeaderRules:       append(hostRules, getSiteHeaderRules(headers, targetURLs)...),
+		CheckAssets:       request.CheckAssets,
+		CheckFragments:    request.CheckFragments,
+		CheckCanonical:    request.CheckCanonical,
+		FollowMetaRefresh: request.FollowMetaRefresh,
+		CheckHreflang:     request.CheckHreflang,
+		CheckSchemes:      request.CheckSchemes,
+		Sitemap:           request.Sitemap,
+		SitemapOnly:       request.SitemapOnly,
+		StripQuery:        request.StripQuery,
+		StripParams:       getStripParams(request.StripParam, request.StripTracking),
+		SortQuery:         request.SortQuery,
+		ExpectText:        expectText,
+		RejectText:        rejectText,
+		MinContentLength:  request.MinContentLength,
+		MinSize:           request.MinSize,
+		ContentTypes:      request.ContentType,
+		HealthyCodes:      healthyCodes,
+		WarningCodes:      warningCodes,
+		Severities:        severities,
+		MaxRedirects:      request.MaxRedirects,
+		IgnoreRobots:      request.IgnoreRobots,
+		HeadFirst:         request.HeadFirst,
+		Timeout:           durations["timeout"],
+		Retries:           request.Retries,
+		SlowThreshold:     durations["slowThreshold"],
+		MaxLinks:          request.MaxLinks,
+		MaxPages:          request.MaxPages,
+	}
+	return options, durations["maxDuration"], nil
+}
+
+// A crawl started through the API, with the results reported so far
+type scan struct {
+	id      string
+	urls    []string
+	started time.Time
+	cancel  context.CancelFunc
+
+	lock     sync.Mutex
+	status   string
+	err      string
+	finished time.Time
+	summary  *linkhealth.Summary
+	results  []jsonResult
+}
+
+// JSON representation of a scan, results are left out when listing scans
+type scanResponse struct {
+	ID       string       `json:"id"`
+	Status   string       `json:"status"`
+	URLs     []string     `json:"urls"`
+	Started  time.Time    `json:"started"`
+	Finished *time.Time   `json:"finished,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Checked  int          `json:"checked"`
+	Healthy  int          `json:"healthy"`
+	Broken   int          `json:"broken"`
+	Warnings int          `json:"warnings"`
+	Results  []jsonResult `json:"results,omitempty"`
+}
+
+func (scan *scan) record(result linkhealth.Result) {
+	scan.lock.Lock()
+	defer scan.lock.Unlock()
+	scan.summary.Add(result)
+	scan.results = append(scan.results, newJSONResult(result))
+}
+
+func (scan *scan) finish(status string, err error) {
+	scan.lock.Lock()
+	defer scan.lock.Unlock()
+	scan.status = status
+	if err != nil {
+		scan.err = err.Error()
+	}
+	scan.finished = time.Now()
+	scan.summary.Finish()
+}
+
+func (scan *scan) response(withResults bool) scanResponse {
+	scan.lock.Lock()
+	defer scan.lock.Unlock()
+	response := scanResponse{
+		ID:       scan.id,
+		Status:   scan.status,
+		URLs:     scan.urls,
+		Started:  scan.started,
+		Error:    scan.err,
+		Checked:  scan.summary.Checked,
+		Healthy:  scan.summary.Healthy,
+		Broken:   scan.summary.Broken,
+		Warnings: scan.summary.Warnings,
+	}
+	if !scan.finished.IsZero() {
+		finished := scan.finished
+		response.Finished = &finished
+	}
+	if withResults {
+		response.Results = append([]jsonResult{}, scan.results...)
+	}
+	return response
+}
+
+// Runs the crawls requested through the API. Scans are kept in memory until deleted or the server stops.
+type scanServer struct {
+	ctx      context.Context
+	maxScans int
+	// Bearer token required in the Authorization header of every request, when set
+	token    string
+	lock     sync.Mutex
+	scans    map[string]*scan
+	running  int
+	scanning sync.WaitGroup
+}
+
+func (server *scanServer) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if !server.authorized(request) {
+		response.Header().Set("WWW-Authenticate", `Bearer realm="scans"`)
+		writeJSONError(response, http.StatusUnauthorized, fmt.Errorf("Pass the API token as a bearer token in the Authorization header"))
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(request.URL.Path, SCANS_PATH), "/")
+	switch {
+	case id == "" && request.Method == http.MethodPost:
+		server.start(response, request)
+	case id == "" && request.Method == http.MethodGet:
+		server.list(response)
+	case id != "" && request.Method == http.MethodGet:
+		if scan := server.scan(id); scan != nil {
+			writeJSON(response, http.StatusOK, scan.response(true))
+		} else {
+			writeJSONError(response, http.StatusNotFound, fmt.Errorf("Unknown scan %s", id))
+		}
+	case id != "" && request.Method == http.MethodDelete:
+		server.delete(response, id)
+	default:
+		writeJSONError(response, http.StatusMethodNotAllowed, fmt.Errorf("%s is not supported on %s", request.Method, request.URL.Path))
+	}
+}
+
+// Checks the bearer token of the request, in constant time so the token cannot be guessed from response times
+func (server *scanServer) authorized(request *http.Request) bool {
+	if server.token == "" {
+		return true
+	}
+	header := request.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(server.token)) == 1
+}
+
+// Starts a crawl with the options of the request body, responding with the created scan
+func (server *scanServer) start(response http.ResponseWriter, request *http.Request) {
+	var body scanRequest
+	decoder := json.NewDecoder(io.LimitReader(request.Body, MAX_SCAN_REQUEST_SIZE))
+	decoder.DisallowUnknownFields()
+	if decodeError := decoder.Decode(&body); decodeError != nil {
+		writeJSONError(response, http.StatusBadRequest, fmt.Errorf("Invalid scan request: %s", decodeError))
+		return
+	}
+	options, maxDuration, optionsError := body.options()
+	if optionsError != nil {
+		writeJSONError(response, http.StatusBadRequest, optionsError)
+		return
+	}
+
+	server.lock.Lock()
+	if server.running >= server.maxScans {
+		server.lock.Unlock()
+		writeJSONError(response, http.StatusTooManyRequests, fmt.Errorf("%d scans are already running, try again later", server.running))
+		return
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if maxDuration > 0 {
+		ctx, cancel = context.WithTimeout(server.ctx, maxDuration)
+	} else {
+		ctx, cancel = context.WithCancel(server.ctx)
+	}
+	scan := &scan{
+		id:      newScanID(),
+		urls:    body.URLs,
+		started: time.Now(),
+		cancel:  cancel,
+		status:  SCAN_RUNNING,
+		summary: linkhealth.NewSummary(),
+	}
+	server.scans[scan.id] = scan
+	server.running++
+	server.scanning.Add(1)
+	server.lock.Unlock()
+
+	go server.run(ctx, scan, options)
+
+	response.Header().Set("Location", SCANS_PATH+"/"+scan.id)
+	writeJSON(response, http.StatusCreated, scan.response(false))
+}
+
+func (server *scanServer) run(ctx context.Context, scan *scan, options linkhealth.Options) {
+	defer server.scanning.Done()
+	defer scan.cancel()
+
+	checker := linkhealth.NewChecker()
+	written := make(chan struct{})
+	go func() {
+		for result := range checker.Results() {
+			scan.record(result)
+		}
+		close(written)
+	}()
+
+	runError := checker.Run(ctx, options)
+	<-written
+	switch {
+	case runError == nil || runError == context.DeadlineExceeded:
+		scan.finish(SCAN_FINISHED, nil)
+	case runError == context.Canceled:
+		scan.finish(SCAN_CANCELLED, nil)
+	default:
+		scan.finish(SCAN_FAILED, runError)
+	}
+
+	server.lock.Lock()
+	server.running--
+	server.lock.Unlock()
+}
+
+// Lists every scan without its results, latest first
+func (server *scanServer) list(response http.ResponseWriter) {
+	server.lock.Lock()
+	scans := make([]scanResponse, 0, len(server.scans))
+	for _, scan := range server.scans {
+		scans = append(scans, scan.response(false))
+	}
+	server.lock.Unlock()
+
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Started.After(scans[j].Started)
+	})
+	writeJSON(response, http.StatusOK, scans)
+}
+
+func (server *scanServer) scan(id string) *scan {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.scans[id]
+}
+
+// Cancels a running scan, or forgets a scan that already stopped
+func (server *scanServer) delete(response http.ResponseWriter, id string) {
+	scan := server.scan(id)
+	if scan == nil {
+		writeJSONError(response, http.StatusNotFound, fmt.Errorf("Unknown scan %s", id))
+		return
+	}
+
+	state := scan.response(false)
+	if state.Status == SCAN_RUNNING {
+		scan.cancel()
+		writeJSON(response, http.StatusAccepted, state)
+		return
+	}
+
+	server.lock.Lock()
+	delete(server.scans, id)
+	server.lock.Unlock()
+	writeJSON(response, http.StatusOK, state)
+}
+
+func newScanID() string {
+	id := make([]byte, 8)
+	if _, randomError := rand.Read(id); randomError != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+func writeJSON(response http.ResponseWriter, status int, value interface{}) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	_ = json.NewEncoder(response).Encode(value)
+}
+
+func writeJSONError(response http.ResponseWriter, status int, err error) {
+	writeJSON(response, status, map[string]string{"error": err.Error()})
+}
+
+// Runs the serve subcommand, serving the scan API until interrupted. Running scans are cancelled on shutdown.
+func runServe(arguments []string) {
+	flags := flag.NewFlagSet(SERVE_COMMAND, flag.ExitOnError)
+	address := flags.String("addr", DEFAULT_SERVE_ADDRESS, "Address to serve the API on")
+	maxScans := flags.Int("maxScans", DEFAULT_MAX_SCANS, "Number of scans allowed to run at the same time")
+	token := flags.String("token", "", "Bearer token required in the Authorization header of API requests, defaults to the SERVE_TOKEN environment variable")
+	applyLogging := addLoggingFlags(flags)
+	_ = flags.Parse(arguments)
+	if loggingError := applyLogging(); loggingError != nil {
+		handleFatal(loggingError)
+	}
+	if *token == "" {
+		*token = os.Getenv(SERVE_TOKEN_VARIABLE)
+	}
+	if *token == "" && !isLoopbackAddress(*address) {
+		handleWarning(fmt.Sprintf("Serving the scan API on %s without a token, anyone reaching it can make the server request any URL", *address))
+	}
+
+	ctx, stop := withShutdownSignals(context.Background())
+	defer stop()
+
+	scans := &scanServer{ctx: ctx, maxScans: *maxScans, token: *token, scans: make(map[string]*scan)}
+	mux := http.NewServeMux()
+	mux.Handle(SCANS_PATH, scans)
+	mux.Handle(SCANS_PATH+"/", scans)
+	server := &http.Server{Addr: *address, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	logs.info(fmt.Sprintf("Serving the scan API on %s%s", *address, SCANS_PATH))
+	if serveError := server.ListenAndServe(); serveError != http.ErrServerClosed {
+		handleFatal(fmt.Errorf("Could not serve on %s: %s", *address, serveError))
+	}
+	scans.scanning.Wait()
+}
+
+// Checks whether the address only listens on the loopback interface
+func isLoopbackAddress(address string) bool {
+	host, _, splitError := net.SplitHostPort(address)
+	if splitError != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Exit code after being interrupted, following the shell convention of 128 + SIGINT
+const EXIT_CODE_INTERRUPTED = 130
+
+// Returns a context cancelled on the first SIGINT or SIGTERM, so the crawl stops making new requests
+// and still reports the links checked so far. A second signal exits immediately.
+func withShutdownSignals(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			signal.Stop(signals)
+			return
+		}
+
+		fmt.Fprintln(os.Stderr, aurora.Yellow("Stopping, waiting for requests in flight. Interrupt again to exit immediately"))
+		cancel()
+
+		<-signals
+		os.Exit(EXIT_CODE_INTERRUPTED)
+	}()
+
+	return ctx, cancel
+}
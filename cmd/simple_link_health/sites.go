@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+)
+
+// Config file key listing independent sites to scan in a single run
+const CONFIG_SITES_KEY = "sites"
+
+// Number of sites crawled at the same time by default
+const DEFAULT_PARALLEL_SITES = 4
+
+// Flags applying to every site of a config file, or to none as with noProgress. Any other option of the crawl
+// is set on each site.
+var SITES_FLAGS = map[string]bool{
+	"config":        true,
+	"parallelSites": true,
+	"output":        true,
+	"maxBroken":     true,
+	"failOn":        true,
+	"noProgress":    true,
+	"verbose":       true,
+	"quiet":         true,
+	"logFormat":     true,
+	"logFile":       true,
+}
+
+// A site of a multi-site config file, with the options of its crawl named like the flags, as in the
+// requests of the serve subcommand
+type siteConfig struct {
+	Name string `json:"name"`
+	scanRequest
+}
+
+// Options of a multi-site run shared by every site
+type sitesOptions struct {
+	parallel  int
+	output    string
+	maxBroken int
+	failOn    string
+}
+
+// Loads the sites listed in a config file, returning none when it does not list any. Sites without a name
+// are named after the host of their first URL.
+func loadSites(path string) ([]siteConfig, error) {
+	values, loadError := loadConfig(path)
+	if loadError != nil {
+		return nil, loadError
+	}
+	entries, found := values[CONFIG_SITES_KEY]
+	if !found {
+		return nil, nil
+	}
+
+	// Sites are decoded like scan requests, YAML maps are converted first as JSON only encodes string keys
+	encoded, encodeError := json.Marshal(getJSONValue(entries))
+	if encodeError != nil {
+		return nil, fmt.Errorf("Invalid sites in config file %s: %s", path, encodeError)
+	}
+	var sites []siteConfig
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if decodeError := decoder.Decode(&sites); decodeError != nil {
+		return nil, fmt.Errorf("Invalid sites in config file %s: %s", path, decodeError)
+	}
+
+	for index := range sites {
+		if sites[index].Name != "" {
+			continue
+		}
+		sites[index].Name = fmt.Sprintf("site %d", index+1)
+		if len(sites[index].URLs) > 0 {
+			if siteURL, urlError := getURL(sites[index].URLs[0]); urlError == nil {
+				sites[index].Name = siteURL.Host
+			}
+		}
+	}
+	return sites, nil
+}
+
+// Returns an error naming the flags set on the command line or in the config file that do not apply to its
+// sites, along with the starting URLs given outside of the sites, rather than crawling without them
+func checkSitesFlags(flags *flag.FlagSet, urls []string) error {
+	var unsupported []string
+	flags.Visit(func(explicitFlag *flag.Flag) {
+		if !SITES_FLAGS[explicitFlag.Name] {
+			unsupported = append(unsupported, "-"+explicitFlag.Name)
+		}
+	})
+	sort.Strings(unsupported)
+	if len(urls) > 0 {
+		unsupported = append(unsupported, "starting URLs outside of the sites")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("Cannot use %s with the sites of a config file, which are crawled with their own options", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// Converts maps decoded from YAML, whose keys may be of any type, into maps with string keys
+func getJSONValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			converted[fmt.Sprint(key)] = getJSONValue(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, 0, len(typed))
+		for _, item := range typed {
+			converted = append(converted, getJSONValue(item))
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// Crawls the sites of a config file concurrently, each with its own options. Text output prints the broken
+// links and summary of each site once it finishes, followed by an overview of every site, while JSON output
+// writes every site with its results once all finished. Exits with 1 when any site has too many broken links.
+func runSites(ctx context.Context, sites []siteConfig, shared sitesOptions) {
+	if shared.output != OUTPUT_TEXT && shared.output != OUTPUT_JSON {
+		handleFatal(fmt.Errorf("Sites of a config file support text or json output, not %q", shared.output))
+	}
+	if shared.parallel <= 0 {
+		shared.parallel = 1
+	}
+
+	// Every site is validated before any is crawled
+	siteOptions := make([]linkhealth.Options, len(sites))
+	maxDurations := make([]time.Duration, len(sites))
+	policies := make([]*failurePolicy, len(sites))
+	for index, site := range sites {
+		options, maxDuration, optionsError := site.options()
+		if optionsError != nil {
+			handleFatal(fmt.Errorf("Invalid site %s: %s", site.Name, optionsError))
+		}
+		if logs.enabled(LOG_LEVEL_DEBUG) {
+			options.Debug = logs.debug
+		}
+		policy, policyError := newFailurePolicy(shared.maxBroken, shared.failOn)
+		if policyError != nil {
+			handleFatal(policyError)
+		}
+		siteOptions[index], maxDurations[index], policies[index] = options, maxDuration, policy
+	}
+
+	scans := make([]*scan, len(sites))
+	slots := make(chan struct{}, shared.parallel)
+	var printing sync.Mutex
+	var running sync.WaitGroup
+	for index := range sites {
+		scans[index] = &scan{id: sites[index].Name, urls: sites[index].URLs, status: SCAN_RUNNING}
+		running.Add(1)
+		go func(index int) {
+			defer running.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			runSite(ctx, scans[index], siteOptions[index], maxDurations[index], policies[index])
+			if shared.output == OUTPUT_TEXT {
+				printing.Lock()
+				printSite(scans[index])
+				printing.Unlock()
+			}
+		}(index)
+	}
+	running.Wait()
+
+	if shared.output == OUTPUT_JSON {
+		responses := make([]scanResponse, 0, len(scans))
+		for _, site := range scans {
+			responses = append(responses, site.response(true))
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeError := encoder.Encode(responses); encodeError != nil {
+			handleFatal(encodeError)
+		}
+	} else {
+		printSitesOverview(scans, policies)
+	}
+
+	failed := 0
+	for _, policy := range policies {
+		if policy.failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintln(os.Stderr, aurora.Red(fmt.Sprintf("Found more than %d broken links on %d of %d sites", shared.maxBroken, failed, len(sites))))
+		os.Exit(EXIT_CODE_BROKEN_LINKS)
+	}
+	if ctx.Err() == context.Canceled {
+		handleWarning("Interrupted, remaining links were not checked")
+		os.Exit(EXIT_CODE_INTERRUPTED)
+	}
+}
+
+// Crawls a single site, recording its results in the scan
+func runSite(ctx context.Context, site *scan, options linkhealth.Options, maxDuration time.Duration, policy *failurePolicy) {
+	if maxDuration > 0 {
+		ctx, site.cancel = context.WithTimeout(ctx, maxDuration)
+	} else {
+		ctx, site.cancel = context.WithCancel(ctx)
+	}
+	defer site.cancel()
+
+	logs.info(fmt.Sprintf("Crawling site %s", site.id))
+	site.started = time.Now()
+	site.summary = linkhealth.NewSummary()
+	checker := linkhealth.NewChecker()
+	written := make(chan struct{})
+	go func() {
+		for result := range checker.Results() {
+			site.record(result)
+			policy.record(result)
+		}
+		close(written)
+	}()
+
+	runError := checker.Run(ctx, options)
+	<-written
+	switch {
+	case runError == nil:
+		site.finish(SCAN_FINISHED, nil)
+	case runError == context.DeadlineExceeded:
+		handleWarning(fmt.Sprintf("Reached maxDuration of %s on site %s, remaining links were not checked", maxDuration, site.id))
+		site.finish(SCAN_FINISHED, nil)
+	case runError == context.Canceled:
+		site.finish(SCAN_CANCELLED, nil)
+	default:
+		handleError(fmt.Errorf("Crawling site %s failed: %s", site.id, runError))
+		site.finish(SCAN_FAILED, runError)
+	}
+}
+
+// Prints the broken links and summary of a finished site
+func printSite(site *scan) {
+	fmt.Println()
+	fmt.Println(aurora.Bold(fmt.Sprintf("Site %s", site.id)))
+	for _, result := range site.results {
+		if result.Healthy || result.Warning != "" {
+			continue
+		}
+		reason := result.Error
+		if reason == "" {
+			reason = fmt.Sprintf("%d", result.Status)
+		}
+		fmt.Printf("%s	%s	%s\n", result.URL, aurora.Red(result.Category), aurora.Red(reason))
+		for _, parent := range result.Parents {
+			fmt.Printf("	linked from %s\n", parent)
+		}
+	}
+	printSummary(site.summary)
+}
+
+// Prints the link counts of every site and of all sites together
+func printSitesOverview(sites []*scan, policies []*failurePolicy) {
+	fmt.Println()
+	fmt.Println(aurora.Bold("Sites"))
+	total := scanResponse{}
+	for index, site := range sites {
+		response := site.response(false)
+		status := response.Status
+		if policies[index].failed() {
+			status = "broken links"
+		}
+		broken := aurora.Green(response.Broken)
+		if response.Broken > 0 {
+			broken = aurora.Red(response.Broken)
+		}
+		fmt.Printf("%s	checked %d	healthy %d	broken %d	warnings %d	%s\n", site.id, response.Checked, response.Healthy, broken, response.Warnings, status)
+		total.Checked += response.Checked
+		total.Healthy += response.Healthy
+		total.Broken += response.Broken
+		total.Warnings += response.Warnings
+	}
+	fmt.Printf("%s	checked %d	healthy %d	broken %d	warnings %d\n", aurora.Bold("All sites"), total.Checked, total.Healthy, total.Broken, total.Warnings)
+}
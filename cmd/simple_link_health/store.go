@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+)
+
+// Subcommand comparing the latest stored run with the previous ones
+const DIFF_COMMAND = "diff"
+
+// Records the results of the crawl in the store once the crawl finishes, along with the validators of the
+// responses when given
+type storeWriter struct {
+	path       string
+	run        linkhealth.StoredRun
+	validators *linkhealth.ResponseValidators
+}
+
+func newStoreWriter(path string, validators *linkhealth.ResponseValidators) *storeWriter {
+	return &storeWriter{path: path, run: linkhealth.StoredRun{Started: time.Now()}, validators: validators}
+}
+
+func (writer *storeWriter) write(result linkhealth.Result) {
+	if result.IsWarning() || result.URL == nil {
+		return
+	}
+	writer.run.Links = append(writer.run.Links, linkhealth.NewStoredLink(result))
+}
+
+func (writer *storeWriter) close() error {
+	store, openError := linkhealth.OpenStore(writer.path)
+	if openError != nil {
+		return openError
+	}
+
+	writer.run.Finished = time.Now()
+	if saveError := store.SaveRun(writer.run); saveError != nil {
+		store.Close()
+		return saveError
+	}
+	if writer.validators != nil {
+		if saveError := store.SaveValidators(writer.validators); saveError != nil {
+			store.Close()
+			return saveError
+		}
+	}
+	return store.Close()
+}
+
+// Loads the validators recorded in the store by previous runs, so unchanged links are revalidated with
+// conditional requests. A store that does not exist yet has no validators.
+func loadValidators(path string) (*linkhealth.ResponseValidators, error) {
+	if _, statError := os.Stat(path); os.IsNotExist(statError) {
+		return linkhealth.NewResponseValidators(), nil
+	}
+	store, openError := linkhealth.OpenStore(path)
+	if openError != nil {
+		return nil, openError
+	}
+	defer store.Close()
+	return store.LoadValidators()
+}
+
+// Loads the median size of every link in the latest runs recorded in the store. A store that does not exist
+// yet has no sizes.
+func loadSizes(path string, runs int) (map[string]int, error) {
+	if _, statError := os.Stat(path); os.IsNotExist(statError) {
+		return nil, nil
+	}
+	store, openError := linkhealth.OpenStore(path)
+	if openError != nil {
+		return nil, openError
+	}
+	defer store.Close()
+	return store.LoadSizes(runs)
+}
+
+// Runs the diff subcommand, printing the links that broke or recovered since the previous run and exiting
+// with 1 when links broke
+func runDiff(arguments []string) {
+	flags := flag.NewFlagSet(DIFF_COMMAND, flag.ExitOnError)
+	storePath := flags.String("store", "", "Store the crawls were recorded in with -store")
+	brokenRuns := flags.Int("brokenRuns", linkhealth.DEFAULT_BROKEN_RUNS, "Report links broken in this many consecutive runs as still broken")
+	_ = flags.Parse(arguments)
+
+	if *storePath == "" {
+		handleFatal(fmt.Errorf("Pass the store to compare runs of with -store"))
+	}
+
+	store, openError := linkhealth.OpenStore(*storePath)
+	if openError != nil {
+		handleFatal(openError)
+	}
+	runs, runsError := store.LatestRuns(*brokenRuns + 1)
+	store.Close()
+	if runsError != nil {
+		handleFatal(runsError)
+	}
+
+	diff, diffError := linkhealth.DiffRuns(runs, *brokenRuns)
+	if diffError != nil {
+		handleFatal(diffError)
+	}
+
+	fmt.Printf("Comparing run %d (%s) with run %d (%s)\n",
+		diff.Latest.ID, diff.Latest.Started.Format("2006-01-02 15:04:05"),
+		diff.Previous.ID, diff.Previous.Started.Format("2006-01-02 15:04:05"))
+	printStoredLinks("Newly broken", diff.NewlyBroken, aurora.Red)
+	printStoredLinks("Newly healthy", diff.NewlyHealthy, aurora.Green)
+	printStoredLinks(fmt.Sprintf("Broken for %d runs", diff.BrokenRuns), diff.StillBroken, aurora.Yellow)
+
+	if len(diff.NewlyBroken) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printStoredLinks(title string, links []linkhealth.StoredLink, color func(interface{}) aurora.Value) {
+	fmt.Println()
+	fmt.Printf("%s	%d\n", aurora.Bold(title), len(links))
+	for _, link := range links {
+		reason := link.Error
+		if reason == "" {
+			reason = fmt.Sprintf("%d", link.Status)
+		}
+		if link.Healthy {
+			reason = "healthy"
+		}
+		fmt.Printf("%s	%s\n", link.URL, color(reason))
+	}
+}
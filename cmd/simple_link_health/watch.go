@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jteer/simple_link_health/pkg/linkhealth"
+	"github.com/logrusorgru/aurora"
+	"github.com/robfig/cron/v3"
+)
+
+const DEFAULT_WATCH_INTERVAL = "1h"
+
+// When the next crawl of watch mode starts
+type watchSchedule interface {
+	Next(time.Time) time.Time
+}
+
+// Starts a crawl every interval after the previous one started
+type intervalSchedule time.Duration
+
+func (interval intervalSchedule) Next(previous time.Time) time.Time {
+	return previous.Add(time.Duration(interval))
+}
+
+// Parses a watch interval given either as a duration such as 15m or as a cron expression such as "0 * * * *"
+func parseWatchSchedule(interval string) (watchSchedule, error) {
+	if duration, durationError := time.ParseDuration(interval); durationError == nil {
+		if duration <= 0 {
+			return nil, fmt.Errorf("Invalid interval %q, expected a positive duration", interval)
+		}
+		return intervalSchedule(duration), nil
+	}
+
+	schedule, cronError := cron.ParseStandard(interval)
+	if cronError != nil {
+		return nil, fmt.Errorf("Invalid interval %q, expected a duration such as 15m or a cron expression: %s", interval, cronError)
+	}
+	return schedule, nil
+}
+
+// How watch mode crawls and what it records besides printing changes
+type watchOptions struct {
+	schedule watchSchedule
+	// Limit of each crawl when positive
+	maxDuration time.Duration
+	// Store every crawl is recorded in when given
+	storePath string
+	// Metrics updated after every crawl when given
+	metrics *watchMetrics
+	// Notified about links that went down
+	notifiers notifiers
+}
+
+// The state of a link in the latest crawl of watch mode, the reason is empty for healthy links
+type watchState struct {
+	status  int
+	reason  string
+	parents []string
+}
+
+// Keeps crawling on the schedule until the context is cancelled, only printing links whose state changed
+// since the previous crawl.
+func runWatch(ctx context.Context, options linkhealth.Options, watch watchOptions) {
+	fmt.Printf("Watching %d URLs, interrupt to stop\n", len(options.URLs))
+	// Links seen by a previous crawl are revalidated with conditional requests
+	if options.Validators == nil {
+		options.Validators = linkhealth.NewResponseValidators()
+	}
+
+	var previous map[string]watchState
+	for {
+		started := time.Now()
+		current, runError := watchCrawl(ctx, options, watch)
+		if ctx.Err() != nil {
+			return
+		}
+		if watch.metrics != nil {
+			watch.metrics.finish(time.Since(started))
+		}
+		if runError == context.DeadlineExceeded {
+			handleWarning(fmt.Sprintf("Reached maxDuration of %s, remaining links were not checked", watch.maxDuration))
+		} else if runError != nil {
+			handleError(runError)
+		}
+
+		if down := printWatchChanges(previous, current); len(down) > 0 && len(watch.notifiers) > 0 {
+			watch.notifiers.notify(newNotification(NOTIFY_EVENT_DOWN, down))
+		}
+		previous = current
+
+		timer := time.NewTimer(time.Until(watch.schedule.Next(started)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Crawls once, returning the state of every checked link
+func watchCrawl(ctx context.Context, options linkhealth.Options, watch watchOptions) (map[string]watchState, error) {
+	if watch.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, watch.maxDuration)
+		defer cancel()
+	}
+
+	var store *storeWriter
+	if watch.storePath != "" {
+		store = newStoreWriter(watch.storePath, options.Validators)
+	}
+
+	checker := linkhealth.NewChecker()
+	states := make(map[string]watchState)
+	written := make(chan struct{})
+	go func() {
+		for result := range checker.Results() {
+			if result.IsWarning() || result.URL == nil {
+				continue
+			}
+			state := watchState{status: result.Status, parents: result.Parents}
+			if result.Err != nil || !result.IsHealthy() {
+				state.reason = getFailureReason(result)
+			}
+			states[result.URL.String()] = state
+			if store != nil {
+				store.write(result)
+			}
+			if watch.metrics != nil {
+				watch.metrics.record(result)
+			}
+		}
+		close(written)
+	}()
+
+	runError := checker.Run(ctx, options)
+	<-written
+	// Crawls stopped by maxDuration are recorded as partial runs, like single crawls, interrupted ones are not
+	if store != nil && ctx.Err() != context.Canceled {
+		if closeError := store.close(); closeError != nil {
+			handleError(closeError)
+		}
+	}
+	return states, runError
+}
+
+// Prints links that broke or recovered since the previous crawl, and broken links of the first crawl.
+// Returns the links that went down.
+func printWatchChanges(previous map[string]watchState, current map[string]watchState) []notifiedLink {
+	links := make([]string, 0, len(current))
+	for link := range current {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+
+	var down []notifiedLink
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for _, link := range links {
+		state := current[link]
+		before, seen := previous[link]
+		switch {
+		case state.reason != "" && (!seen || before.reason == ""):
+			fmt.Printf("%s	%s	%s	%s%s\n", now, link, aurora.Red("down"), state.reason, getLinkedFrom(state.parents))
+			down = append(down, notifiedLink{URL: link, Status: state.status, Reason: state.reason, Parents: state.parents})
+		case state.reason == "" && seen && before.reason != "":
+			fmt.Printf("%s	%s	%s\n", now, link, aurora.Green("recovered"))
+		}
+	}
+	return down
+}
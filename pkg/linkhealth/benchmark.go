@@ -0,0 +1,120 @@
+package linkhealth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+const (
+	DEFAULT_BENCHMARK_REQUESTS    = 100
+	DEFAULT_BENCHMARK_CONCURRENCY = 4
+)
+
+// Latencies and errors collected while benchmarking a single URL
+type BenchmarkResult struct {
+	// Latency of every completed request, sorted from fastest to slowest
+	Latencies []time.Duration
+	Errors    int
+	Duration  time.Duration
+	lock      sync.Mutex
+}
+
+func (result *BenchmarkResult) record(latency time.Duration, failed bool) {
+	result.lock.Lock()
+	defer result.lock.Unlock()
+	result.Latencies = append(result.Latencies, latency)
+	if failed {
+		result.Errors++
+	}
+}
+
+// Returns the latency at the percentile using the nearest-rank method
+func (result *BenchmarkResult) Percentile(percentile int) time.Duration {
+	if len(result.Latencies) == 0 {
+		return 0
+	}
+
+	rank := (percentile*len(result.Latencies) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return result.Latencies[rank-1]
+}
+
+// Returns the percentage of requests that failed
+func (result *BenchmarkResult) ErrorRate() float64 {
+	if len(result.Latencies) == 0 {
+		return 0
+	}
+	return 100 * float64(result.Errors) / float64(len(result.Latencies))
+}
+
+// Repeatedly requests a single URL without following links and collects the latency distribution.
+// The user agent, header rules and cookies of the options are used for every request.
+func Benchmark(ctx context.Context, targetURL string, requests int, concurrency int, options Options) (*BenchmarkResult, error) {
+	options = options.withDefaults()
+	collector := colly.NewCollector(
+		colly.Async(true),
+		colly.UserAgent(options.UserAgent),
+		colly.AllowURLRevisit(),
+	)
+
+	limitError := collector.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: concurrency,
+	})
+
+	if limitError != nil {
+		return nil, limitError
+	}
+
+	transport := getTransport(newCertificateTracker(), options)
+	transport.MaxIdleConnsPerHost = concurrency
+	collector.WithTransport(transport)
+
+	if cookieError := setCookies(collector, options); cookieError != nil {
+		return nil, cookieError
+	}
+
+	result := &BenchmarkResult{}
+
+	// Every top level visit gets its own context, so the start time is tracked per request
+	collector.OnRequest(func(request *colly.Request) {
+		if ctx.Err() != nil {
+			request.Abort()
+			return
+		}
+
+		applyHeaderRules(options.HeaderRules, request.URL.Hostname(), *request.Headers)
+		request.Ctx.Put("start", time.Now())
+	})
+
+	collector.OnResponse(func(response *colly.Response) {
+		start := response.Ctx.GetAny("start").(time.Time)
+		result.record(time.Since(start), false)
+	})
+
+	collector.OnError(func(response *colly.Response, err error) {
+		start, ok := response.Ctx.GetAny("start").(time.Time)
+		if !ok {
+			return
+		}
+		result.record(time.Since(start), true)
+	})
+
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		if visitError := collector.Visit(targetURL); visitError != nil {
+			return nil, visitError
+		}
+	}
+	collector.Wait()
+
+	result.Duration = time.Since(start)
+	sort.Slice(result.Latencies, func(i, j int) bool { return result.Latencies[i] < result.Latencies[j] })
+	return result, ctx.Err()
+}
@@ -0,0 +1,87 @@
+package linkhealth
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Limits the total number of requests in two phases. Once the soft limit is reached no new links are
+// discovered but already discovered links are still checked, once the hard limit is reached no more
+// requests are made. A limit of 0 disables that phase. Independently, links are only followed on
+// the first pageLimit pages when it is positive.
+type linkBudget struct {
+	softLimit int64
+	hardLimit int64
+	requested int64
+	softOnce  sync.Once
+	hardOnce  sync.Once
+	pageLimit int
+	pagesLock sync.Mutex
+	// Pages whose links are followed
+	pages    map[string]bool
+	pageOnce sync.Once
+	// Called once when each limit is reached
+	warn func(message string)
+}
+
+func newLinkBudget(softLimit int, hardLimit int, pageLimit int, warn func(message string)) (*linkBudget, error) {
+	if softLimit < 0 || hardLimit < 0 || pageLimit < 0 {
+		return nil, fmt.Errorf("Link limits must not be negative")
+	}
+	if softLimit > 0 && hardLimit > 0 && softLimit > hardLimit {
+		return nil, fmt.Errorf("softMaxLinks (%d) must not exceed maxLinks (%d)", softLimit, hardLimit)
+	}
+
+	return &linkBudget{
+		softLimit: int64(softLimit),
+		hardLimit: int64(hardLimit),
+		pageLimit: pageLimit,
+		pages:     make(map[string]bool),
+		warn:      warn,
+	}, nil
+}
+
+// Counts a new request, returns false when the hard limit was already reached and the request should not be made
+func (budget *linkBudget) request() bool {
+	requested := atomic.AddInt64(&budget.requested, 1)
+	if budget.hardLimit > 0 && requested > budget.hardLimit {
+		budget.hardOnce.Do(func() {
+			budget.warn(fmt.Sprintf("Reached maxLinks limit of %d, remaining links will not be checked", budget.hardLimit))
+		})
+		return false
+	}
+	return true
+}
+
+// Checks whether the links found on the page should be followed, counting the page towards the page limit
+func (budget *linkBudget) following(page string) bool {
+	if budget.pageLimit <= 0 {
+		return true
+	}
+
+	budget.pagesLock.Lock()
+	defer budget.pagesLock.Unlock()
+	if budget.pages[page] {
+		return true
+	}
+	if len(budget.pages) >= budget.pageLimit {
+		budget.pageOnce.Do(func() {
+			budget.warn(fmt.Sprintf("Reached maxPages limit of %d, no longer following links on further pages", budget.pageLimit))
+		})
+		return false
+	}
+	budget.pages[page] = true
+	return true
+}
+
+// Checks whether links found on pages should still be followed
+func (budget *linkBudget) discovering() bool {
+	if budget.softLimit > 0 && atomic.LoadInt64(&budget.requested) >= budget.softLimit {
+		budget.softOnce.Do(func() {
+			budget.warn(fmt.Sprintf("Reached softMaxLinks limit of %d, no longer following new links", budget.softLimit))
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly"
+)
+
+// Selectors of the canonical URL and the meta refresh tag of a page
+const (
+	CANONICAL_SELECTOR    = "link[rel=canonical][href]"
+	META_REFRESH_SELECTOR = "meta[http-equiv][content]"
+)
+
+// Collects the canonical URLs and immediate meta refreshes of parsed pages, so links to them can be
+// reported once the crawl finishes, when every page linking to them is known
+type canonicalTracker struct {
+	lock sync.Mutex
+	// Canonical URL of each page declaring another URL as canonical
+	canonicals map[string]string
+	// Target of each page refreshing to another URL without delay
+	refreshes map[string]string
+}
+
+func newCanonicalTracker() *canonicalTracker {
+	return &canonicalTracker{canonicals: make(map[string]string), refreshes: make(map[string]string)}
+}
+
+// Records the canonical URL of the page when it is another URL
+func (tracker *canonicalTracker) canonical(element *colly.HTMLElement, stripQuery bool) {
+	page := element.Request.URL.String()
+	canonical := element.Request.AbsoluteURL(cleanHref(element.Attr("href")))
+	if canonical == "" || NormalizeURL(canonical, stripQuery) == NormalizeURL(page, stripQuery) {
+		return
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.canonicals[page] = canonical
+}
+
+// Records the target of the page when it refreshes to another URL without delay
+func (tracker *canonicalTracker) refresh(page *url.URL, target string, delay int) {
+	if delay > 0 || NormalizeURL(target, false) == NormalizeURL(page.String(), false) {
+		return
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.refreshes[page.String()] = target
+}
+
+// Returns a warning for every page linked as a non-canonical URL or immediately refreshing elsewhere, with
+// the pages linking to it. Pages nothing links to are only reported when they refresh elsewhere.
+func (tracker *canonicalTracker) warnings(linkedFrom func(link string) []string) []Result {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	var results []Result
+	for _, page := range sortedKeys(tracker.canonicals) {
+		parents := linkedFrom(page)
+		if len(parents) == 0 {
+			continue
+		}
+		pageURL, _ := url.Parse(page)
+		message := fmt.Sprintf("Links to %s on %s point at a non-canonical URL, its canonical URL is %s", page, strings.Join(parents, ", "), tracker.canonicals[page])
+		results = append(results, Result{Link: Link{URL: pageURL, Parents: parents}, Warning: message})
+	}
+	for _, page := range sortedKeys(tracker.refreshes) {
+		parents := linkedFrom(page)
+		pageURL, _ := url.Parse(page)
+		message := fmt.Sprintf("%s immediately refreshes to %s, consider linking to it instead", page, tracker.refreshes[page])
+		if len(parents) > 0 {
+			message += " on " + strings.Join(parents, ", ")
+		}
+		results = append(results, Result{Link: Link{URL: pageURL, Parents: parents}, Warning: message})
+	}
+	return results
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Parses the content of a meta refresh tag, e.g. "0; url=/new-page", returning its delay in seconds and
+// its target. Tags only reloading the page have no target.
+func parseMetaRefresh(content string) (int, string, bool) {
+	parts := strings.SplitN(content, ";", 2)
+	if len(parts) == 1 {
+		parts = strings.SplitN(content, ",", 2)
+	}
+	delay, delayError := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if delayError != nil || delay < 0 {
+		return 0, "", false
+	}
+	if len(parts) == 1 {
+		return int(delay), "", true
+	}
+
+	target := strings.TrimSpace(parts[1])
+	if separator := strings.Index(target, "="); separator >= 0 && strings.EqualFold(strings.TrimSpace(target[:separator]), "url") {
+		target = strings.TrimSpace(target[separator+1:])
+	}
+	target = strings.Trim(target, `"'`)
+	return int(delay), target, true
+}
@@ -0,0 +1,35 @@
+package linkhealth
+
+import "testing"
+
+func TestParseMetaRefresh(t *testing.T) {
+	tests := []struct {
+		content    string
+		wantDelay  int
+		wantTarget string
+		wantValid  bool
+	}{
+		{"0; url=/new-page", 0, "/new-page", true},
+		{"0;URL=/new-page", 0, "/new-page", true},
+		{"5 ; Url = https://site.com/new ", 5, "https://site.com/new", true},
+		{"3; url='/quoted'", 3, "/quoted", true},
+		{`3; url="/double-quoted"`, 3, "/double-quoted", true},
+		{"0; /without-url-key", 0, "/without-url-key", true},
+		{"1, url=/comma", 1, "/comma", true},
+		{"2.5; url=/fractional", 2, "/fractional", true},
+		{"0; url=/search?q=a=b", 0, "/search?q=a=b", true},
+		{"30", 30, "", true},
+		{" 10 ", 10, "", true},
+		{"", 0, "", false},
+		{"soon; url=/page", 0, "", false},
+		{"-1; url=/page", 0, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.content, func(t *testing.T) {
+			delay, target, valid := parseMetaRefresh(test.content)
+			if delay != test.wantDelay || target != test.wantTarget || valid != test.wantValid {
+				t.Errorf("parseMetaRefresh(%q) = %d, %q, %t, want %d, %q, %t", test.content, delay, target, valid, test.wantDelay, test.wantTarget, test.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package linkhealth
+
+import (
+	"strings"
+	"sync"
+)
+
+// Tracks the seen URLs by a case-folded key to detect links differing from a seen URL only by case
+type caseTracker struct {
+	lock sync.Mutex
+	seen map[string]string
+}
+
+func newCaseTracker() *caseTracker {
+	return &caseTracker{seen: make(map[string]string)}
+}
+
+// Records the URL and returns the first seen casing of it, along with
+// whether the URL differs from that casing
+func (tracker *caseTracker) check(link string) (string, bool) {
+	key := strings.ToLower(link)
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if firstSeen, ok := tracker.seen[key]; ok {
+		return firstSeen, firstSeen != link
+	}
+
+	tracker.seen[key] = link
+	return link, false
+}
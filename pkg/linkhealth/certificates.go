@@ -0,0 +1,119 @@
+package linkhealth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Records the leaf certificates presented during TLS handshakes to look up the expiry of each host
+type certificateTracker struct {
+	lock sync.RWMutex
+	// Distinct leaf certificates in the order they were first presented
+	leaves   []*x509.Certificate
+	seen     map[string]bool
+	expiries map[string]time.Time
+	warned   map[string]bool
+}
+
+func newCertificateTracker() *certificateTracker {
+	return &certificateTracker{
+		seen:     make(map[string]bool),
+		expiries: make(map[string]time.Time),
+		warned:   make(map[string]bool),
+	}
+}
+
+// Stores the leaf certificate, used as the VerifyPeerCertificate callback of the TLS config. The callback is
+// not given the server name, so hosts are matched against the names of the certificate when looked up.
+func (tracker *certificateTracker) verifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if tracker.seen[string(rawCerts[0])] {
+		return nil
+	}
+	leaf, parseError := x509.ParseCertificate(rawCerts[0])
+	if parseError != nil {
+		// Invalid certificates fail the handshake and are reported with the link
+		return nil
+	}
+	tracker.seen[string(rawCerts[0])] = true
+	tracker.leaves = append(tracker.leaves, leaf)
+	// A renewed certificate replaces the expiry already looked up for its hosts
+	for host := range tracker.expiries {
+		if leaf.VerifyHostname(host) == nil {
+			tracker.expiries[host] = leaf.NotAfter
+		}
+	}
+	return nil
+}
+
+// Returns the expiry of the latest certificate recorded for the host, if any
+func (tracker *certificateTracker) expiry(host string) (time.Time, bool) {
+	tracker.lock.RLock()
+	expiry, ok := tracker.expiries[host]
+	tracker.lock.RUnlock()
+	if ok {
+		return expiry, true
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	for index := len(tracker.leaves) - 1; index >= 0; index-- {
+		if tracker.leaves[index].VerifyHostname(host) == nil {
+			tracker.expiries[host] = tracker.leaves[index].NotAfter
+			return tracker.leaves[index].NotAfter, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns true the first time it is called for a host so each expiring certificate is only warned about once
+func (tracker *certificateTracker) shouldWarn(host string) bool {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if tracker.warned[host] {
+		return false
+	}
+	tracker.warned[host] = true
+	return true
+}
+
+// Creates the HTTP transport used by the collector, recording certificates seen during TLS handshakes
+// and sending requests through the proxies when given, with the connection settings of the options
+func getTransport(certificates *certificateTracker, options Options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = getProxyFunc(options.Proxies)
+	transport.TLSClientConfig = &tls.Config{
+		VerifyPeerCertificate: certificates.verifyPeerCertificate,
+		RootCAs:               options.RootCAs,
+		InsecureSkipVerify:    options.Insecure,
+	}
+	tuneTransport(transport, options)
+	return transport
+}
+
+// Loads the PEM encoded CA certificates of a bundle, trusted along with the system certificates
+func LoadCACertificates(path string) (*x509.CertPool, error) {
+	content, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, readError
+	}
+
+	pool, poolError := x509.SystemCertPool()
+	if poolError != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("No PEM encoded certificates found in %s", path)
+	}
+	return pool, nil
+}
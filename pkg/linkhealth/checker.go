@@ -0,0 +1,941 @@
+// Package linkhealth crawls sites and checks the health of every link found, so link checking
+// can be embedded in other Go programs. The simple_link_health command is a thin wrapper around it.
+package linkhealth
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+const (
+	DEFAULT_USER_AGENT            = "Simple_Link_Health_BOT"
+	DEFAULT_DEPTH                 = 2
+	DEFAULT_THREADS               = 4
+	DEFAULT_CERT_EXPIRY_WARN_DAYS = 30
+	RESULTS_BUFFER_SIZE           = 64
+)
+
+// Options used to configure a crawl
+type Options struct {
+	// URLs the crawl starts from
+	URLs      []*url.URL
+	UserAgent string
+	Depth     int
+	Threads   int
+	// Warn when a TLS certificate expires within this many days, 0 disables the warning
+	CertExpiryWarn       int
+	HeaderRules          []HeaderRule
+	ReportMalformedHrefs bool
+	// Enables the Content-Language check when set, see CompileLocalePattern
+	LocalePattern *regexp.Regexp
+	CheckCase     bool
+	// Also check images, scripts, stylesheets and iframes, see ASSET_SELECTORS
+	CheckAssets bool
+	// Also check every page listed in the sitemap.xml of each starting URL's site
+	Sitemap bool
+	// Only check the pages listed in the sitemaps, without crawling the starting URLs or following links
+	SitemapOnly bool
+	// How links to hosts other than the ones of the starting URLs are handled, one of EXTERNAL_CHECK
+	// (the default), EXTERNAL_SKIP or EXTERNAL_CRAWL
+	External string
+	// Hosts crawled like the hosts of the starting URLs, * matches any part of a host, e.g. *.example.com
+	AllowedDomains []string
+	// Hosts never requested, even when allowed or linked
+	DisallowedDomains []string
+	// Parallel requests to external hosts, defaults to Threads. Requests are made by Threads + ExternalThreads workers
+	ExternalThreads int
+	// Links kept in memory while waiting to be requested, defaults to DEFAULT_QUEUE_SIZE. Further links found
+	// while crawling wait in a temporary file, sitemap pages and starting URLs wait for space in the queue
+	QueueSize int
+	// Discovered links matching any exclude pattern are not visited, and when there are include patterns
+	// only links matching one of them are visited. Starting URLs are always visited, see CompileURLPattern
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+	// Report links to fragments missing from the anchors of the page they point to
+	CheckFragments bool
+	// Warn about links to pages declaring another URL as canonical, and pages immediately refreshing to
+	// another URL with a meta refresh tag
+	CheckCanonical bool
+	// Check the targets of meta refresh tags like the targets of redirects, at the depth of the refreshing page
+	FollowMetaRefresh bool
+	// Check the alternate language versions declared with hreflang, warning about invalid language codes and
+	// alternates not declaring the page back
+	CheckHreflang bool
+	// Statuses of healthy links, defaults to DEFAULT_HEALTHY_CODES
+	HealthyCodes StatusCodes
+	// Statuses reported as warnings instead of broken links, e.g. 403 for sites blocking bots
+	WarningCodes StatusCodes
+	// Redirects followed before a link is reported as broken, defaults to DEFAULT_MAX_REDIRECTS
+	MaxRedirects int
+	// Warn about links permanently redirecting within the same host, which should be updated to the new URL
+	WarnPermanentRedirects bool
+	// Cookies sent along with requests. Cookies with a domain are sent to that domain, other cookies to
+	// the hosts of the starting URLs. Cookies set by responses are kept for later requests
+	Cookies []*http.Cookie
+	// Submitted before crawling, the session cookies it sets are sent with every request
+	Login *LoginForm
+	// Proxies requests are sent through, rotating between them. Without proxies the proxy environment
+	// variables are used
+	Proxies []*url.URL
+	// Rate and parallelism limits of specific hosts, the first matching limit with a rate or parallelism applies
+	HostLimits []HostLimit
+	// Validate links with schemes other than http and https, such as mailto and tel links, and warn about
+	// suspicious schemes such as javascript
+	CheckSchemes bool
+	// Look up the mail servers of the domains of mailto links when checking schemes
+	CheckMX bool
+	// Ignore query strings, checking each page once without its query
+	StripQuery bool
+	// Query and path parameters to remove from discovered links before visiting them, e.g. utm_* or
+	// sessionid, matched case-insensitively in path.Match syntax. See TRACKING_PARAMS
+	StripParams []string
+	// Sort the query parameters of discovered links before visiting them
+	SortQuery bool
+	// How the links of pages are extracted, one of RENDER_HTML (the default) or RENDER_BROWSER
+	Render string
+	// Look up the closest archived snapshot of links that are gone, see WAYBACK_STATUS_CODES
+	Wayback bool
+	// Availability API archived snapshots are looked up with, defaults to DEFAULT_WAYBACK_API
+	WaybackAPI string
+	// Soft failures reported as broken links despite a healthy status, see checkContent. Every expected
+	// pattern must match the body of a text response and no rejected pattern may match it
+	ExpectText []*regexp.Regexp
+	RejectText []*regexp.Regexp
+	// Responses with a shorter body are reported as broken, no minimum when 0
+	MinContentLength int
+	// Warn about healthy responses smaller than this many bytes, without reporting them as broken
+	MinSize int
+	// Warn about healthy responses whose size differs from their size in PreviousSizes by more than this
+	// many percent
+	SizeDeviation int
+	// Sizes of links in previous runs by URL, see Store.LoadSizes
+	PreviousSizes map[string]int
+	// Media types of healthy responses, * matches any subtype, e.g. text/html or image/*. Any type when empty
+	ContentTypes []string
+	// Healthy links taking longer than this to respond are reported as slow, no threshold when 0
+	SlowThreshold time.Duration
+	// Severities results are reported with, defaults to DEFAULT_SEVERITIES
+	Severities SeverityRules
+	// Skip TLS certificate verification, e.g. for internal hosts with self signed certificates
+	Insecure bool
+	// CA certificates trusted when verifying TLS certificates, defaults to the system certificates
+	RootCAs *x509.CertPool
+	// Only use HTTP/1.1, HTTP/2 is used with servers supporting it otherwise
+	DisableHTTP2 bool
+	// Idle connections kept open per host, defaults to the number of workers so parallel requests reuse them
+	MaxIdleConnsPerHost int
+	// How long idle connections are kept open, defaults to DEFAULT_IDLE_CONN_TIMEOUT
+	IdleConnTimeout time.Duration
+	// Open a new connection for every request
+	DisableKeepAlives bool
+	// IP version connected with first, IP_VERSION_4 or IP_VERSION_6, falling back to any address. Empty
+	// connects in the order the addresses resolve
+	PreferIPVersion string
+	// Request disallowed links and ignore Crawl-delay directives, robots.txt is respected by default
+	IgnoreRobots bool
+	// Make HEAD requests for links whose body is not needed, such as links at the max depth or assets
+	HeadFirst bool
+	// Validators of the links seen by previous crawls when set. Links whose body is not needed are requested
+	// with conditional requests, 304 Not Modified responses are healthy, and the validators of responses
+	// are recorded for the next crawl. Links are revalidated unless content checks are enabled
+	Validators *ResponseValidators
+	// Timeout of each request, including reading the response body. Retries are timed separately
+	Timeout time.Duration
+	// Number of times a request failing with a transient error is retried, see the retryTransport type
+	Retries int
+	// Base wait before the first retry, doubled for each further retry
+	RetryDelay time.Duration
+	// Request budgets, see the linkBudget type. 0 disables the limit
+	SoftMaxLinks int
+	MaxLinks     int
+	// Follow the links of at most this many pages, still checking the links found on them. 0 disables the limit
+	MaxPages int
+	// File the state of the crawl is periodically written to. When the file exists the crawl resumes from it,
+	// reporting the links it checked again without requesting them. It is removed once the crawl finishes
+	Checkpoint string
+	// Checks of every link with a healthy status, a link failing any of them is reported as broken
+	LinkValidators []LinkValidator
+	// Callbacks of the crawl, see the Hooks type
+	Hooks Hooks
+	// Called with debug messages when set, such as every queued link and retried request
+	Debug func(message string)
+}
+
+// Maps the selectors of the assets checked with the CheckAssets option to the attribute holding their URL
+var ASSET_SELECTORS = map[string]string{
+	"img[src]":             "src",
+	"script[src]":          "src",
+	"link[rel=stylesheet]": "href",
+	"iframe[src]":          "src",
+}
+
+// Selectors of the assets whose srcset attribute lists candidate URLs
+var SRCSET_SELECTORS = []string{"img[srcset]", "source[srcset]"}
+
+// Fills in the defaults of any unset options
+func (options Options) withDefaults() Options {
+	if options.UserAgent == "" {
+		options.UserAgent = DEFAULT_USER_AGENT
+	}
+	if options.Threads < 1 {
+		options.Threads = DEFAULT_THREADS
+	}
+	if len(options.HealthyCodes) == 0 {
+		options.HealthyCodes, _ = ParseStatusCodes(DEFAULT_HEALTHY_CODES)
+	}
+	if options.MaxRedirects <= 0 {
+		options.MaxRedirects = DEFAULT_MAX_REDIRECTS
+	}
+	if options.External == "" {
+		options.External = EXTERNAL_CHECK
+	}
+	if options.ExternalThreads < 1 {
+		options.ExternalThreads = options.Threads
+	}
+	if options.QueueSize < 1 {
+		options.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DEFAULT_REQUEST_TIMEOUT
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = DEFAULT_RETRY_DELAY
+	}
+	if options.Render == "" {
+		options.Render = RENDER_HTML
+	}
+	if options.WaybackAPI == "" {
+		options.WaybackAPI = DEFAULT_WAYBACK_API
+	}
+	if options.Severities == nil {
+		options.Severities = DEFAULT_SEVERITIES
+	}
+	return options
+}
+
+// Sends a debug message when the Debug option is set
+func (options Options) debugf(format string, args ...interface{}) {
+	if options.Debug != nil {
+		options.Debug(fmt.Sprintf(format, args...))
+	}
+}
+
+// Crawls sites from the starting URLs and reports the health of every link found.
+// A checker runs a single crawl, its results channel is closed once Run returns.
+type Checker struct {
+	results    chan Result
+	parents    *parentTracker
+	progress   *progressCounters
+	checkpoint *checkpointer
+	severities SeverityRules
+	onResult   func(result Result)
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		results:  make(chan Result, RESULTS_BUFFER_SIZE),
+		parents:  newParentTracker(),
+		progress: newProgressCounters(),
+	}
+}
+
+// Returns the channel results are sent on while crawling. It must be drained for the crawl to progress.
+func (checker *Checker) Results() <-chan Result {
+	return checker.results
+}
+
+// Returns every page the link has been found on. Links can be found on more pages after they were
+// checked, so the pages are only complete once Run returns.
+func (checker *Checker) LinkedFrom(link *url.URL) []string {
+	return checker.parents.linkedFrom(link.String())
+}
+
+// Returns the counters of the crawl so far, safe to call while crawling
+func (checker *Checker) Progress() Progress {
+	return checker.progress.snapshot()
+}
+
+// Crawls from every starting URL, blocking until the crawl finishes. Once the context is
+// cancelled no new requests are made, and the context error is returned after in-flight requests finish.
+func (checker *Checker) Run(ctx context.Context, options Options) error {
+	defer close(checker.results)
+	checker.progress.start()
+
+	options = options.withDefaults()
+	checker.severities = options.Severities
+	checker.onResult = options.Hooks.OnResult
+	if options.SitemapOnly {
+		options.Sitemap = true
+		options.Depth = 1
+	}
+
+	budget, budgetError := newLinkBudget(options.SoftMaxLinks, options.MaxLinks, options.MaxPages, func(message string) {
+		checker.warn(nil, message)
+	})
+	if budgetError != nil {
+		return budgetError
+	}
+
+	if options.PreferIPVersion != "" && options.PreferIPVersion != IP_VERSION_4 && options.PreferIPVersion != IP_VERSION_6 {
+		return fmt.Errorf("Invalid IP version %q, expected %s or %s", options.PreferIPVersion, IP_VERSION_4, IP_VERSION_6)
+	}
+	if !containsString(RENDER_MODES, options.Render) {
+		return fmt.Errorf("Invalid render value %q, expected one of %s", options.Render, strings.Join(RENDER_MODES, ", "))
+	}
+	var renderer *browserRenderer
+	if options.Render == RENDER_BROWSER {
+		var rendererError error
+		if renderer, rendererError = newBrowserRenderer(options); rendererError != nil {
+			return rendererError
+		}
+		defer renderer.close()
+	}
+
+	if options.Checkpoint != "" {
+		saved, checkpointError := newCheckpointer(options.Checkpoint, options.URLs)
+		if checkpointError != nil {
+			return checkpointError
+		}
+		checker.checkpoint = saved
+	}
+
+	fragments := newFragmentTracker()
+	canonicals := newCanonicalTracker()
+	hreflangs := newHreflangTracker()
+	queue := newLinkQueue(options, checker.progress)
+	defer queue.close()
+	collector, collectorError := checker.getCollector(ctx, options, budget, fragments, canonicals, hreflangs, renderer, queue)
+	if collectorError != nil {
+		return collectorError
+	}
+
+	if options.Login != nil {
+		if loginError := login(ctx, options.Login, options, &collectorJar{collector: collector}); loginError != nil {
+			return loginError
+		}
+	}
+
+	if saved := checker.checkpoint; saved != nil {
+		if saved.resuming() {
+			options.debugf("Resuming from checkpoint %s with %d checked and %d queued links", options.Checkpoint, len(saved.checked), len(saved.pending))
+		}
+		checker.parents.restore(saved.parents)
+		for _, result := range saved.results() {
+			checker.progress.check(result.Err != nil || !result.Healthy)
+			checker.send(result)
+		}
+		for _, queued := range saved.pending {
+			queue.push(queued)
+		}
+		stopSaving := saved.autosave(queue, checker.parents, func(err error) {
+			checker.warn(nil, err.Error())
+		})
+		defer func() {
+			stopSaving()
+			checker.saveCheckpoint(ctx, queue)
+		}()
+	}
+
+	queue.start(ctx, options.Threads+options.ExternalThreads)
+	if options.Sitemap {
+		checker.visitSitemaps(ctx, options, queue)
+	}
+
+	if !options.SitemapOnly {
+		for _, targetURL := range options.URLs {
+			if !queue.pushWait(ctx, queuedLink{Link: targetURL.String(), Depth: 1, Seed: true}) {
+				break
+			}
+		}
+	}
+	queue.finish()
+
+	if options.CheckFragments {
+		for _, result := range fragments.missing() {
+			for _, parent := range result.Parents {
+				checker.parents.discovered(result.URL.String(), parent)
+			}
+			checker.send(result)
+		}
+	}
+	if options.CheckCanonical {
+		for _, result := range canonicals.warnings(checker.parents.linkedFrom) {
+			result.CheckedAt = time.Now()
+			checker.send(result)
+		}
+	}
+	if options.CheckHreflang {
+		for _, result := range hreflangs.warnings() {
+			result.CheckedAt = time.Now()
+			checker.send(result)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Writes the checkpoint when the crawl was interrupted, and removes it once the crawl finished
+func (checker *Checker) saveCheckpoint(ctx context.Context, queue *linkQueue) {
+	var checkpointError error
+	if ctx.Err() != nil {
+		checkpointError = checker.checkpoint.save(queue, checker.parents)
+	} else {
+		checkpointError = checker.checkpoint.remove()
+	}
+	if checkpointError != nil {
+		checker.warn(nil, checkpointError.Error())
+	}
+}
+
+// Visits every page listed in the sitemap of each starting URL's site, reporting sitemaps that could not be loaded
+func (checker *Checker) visitSitemaps(ctx context.Context, options Options, queue *linkQueue) {
+	loader := &sitemapLoader{
+		client: &http.Client{
+			Transport: getTransport(newCertificateTracker(), options),
+			Timeout:   SITEMAP_TIMEOUT,
+		},
+		options: options,
+		seen:    make(map[string]bool),
+		found: func(link string, sitemap string) {
+			if !isIncluded(options, link) {
+				return
+			}
+			checker.parents.discovered(link, sitemap)
+			queue.pushWait(ctx, queuedLink{Link: link, Depth: 1})
+		},
+		failed: func(sitemap string, status int, err error) {
+			sitemapURL, _ := url.Parse(sitemap)
+			checker.send(Result{Link: Link{URL: sitemapURL, Status: status, CheckedAt: time.Now()}, Err: err})
+		},
+	}
+
+	for _, targetURL := range options.URLs {
+		loader.load(ctx, getSitemapURL(targetURL), 1)
+	}
+}
+
+// Reports a warning about the link, the link is nil for warnings about the crawl as a whole
+func (checker *Checker) warn(link *url.URL, message string) {
+	checker.warnAs(link, "", message)
+}
+
+// Reports a warning of one of the WARNING_KIND constants, whose severity can be changed
+func (checker *Checker) warnAs(link *url.URL, kind string, message string) {
+	checker.send(Result{Link: Link{URL: link, CheckedAt: time.Now()}, Warning: message, WarningKind: kind})
+}
+
+// Sends a result with its severity
+func (checker *Checker) send(result Result) {
+	result.Severity = checker.severities.Severity(result)
+	if checker.onResult != nil {
+		checker.onResult(result)
+	}
+	checker.results <- result
+}
+
+// Sends the result of a checked link. Links with a healthy or warning status are healthy, even when colly
+// reported their status as an error, and links with a warning status are reported with a warning. Content
+// and custom check failures are reported regardless of the status, and unchanged links healthy when
+// revalidating them.
+func (checker *Checker) report(options Options, link Link, err error) {
+	switch {
+	case isSoftError(err):
+		// The status is healthy but the content or a custom check is not
+	case options.Validators != nil && link.Status == http.StatusNotModified:
+		link.Healthy = true
+		err = nil
+	case options.HealthyCodes.Contains(link.Status):
+		link.Healthy = true
+		err = nil
+	case options.WarningCodes.Contains(link.Status):
+		link.Healthy = true
+		err = nil
+		checker.warn(link.URL, fmt.Sprintf("%s responded with %d %s", link.URL, link.Status, http.StatusText(link.Status)))
+	}
+
+	if link.Healthy && options.SlowThreshold > 0 && link.Latency > options.SlowThreshold {
+		link.Slow = true
+		checker.warnAs(link.URL, WARNING_KIND_SLOW, fmt.Sprintf("%s took %s to respond, slower than %s", link.URL, link.Latency.Round(time.Millisecond), options.SlowThreshold))
+	}
+
+	if link.Healthy && link.HasSize {
+		if message := checkSize(options, link); message != "" {
+			checker.warnAs(link.URL, WARNING_KIND_SIZE, message)
+		}
+	}
+
+	if options.WarnPermanentRedirects && isPermanentSameHostRedirect(link.Redirects) {
+		message := fmt.Sprintf("%s permanently redirects to %s, consider updating the link", link.Redirects[0].URL, link.Redirects[0].Location)
+		if len(link.Parents) > 0 {
+			message += " on " + strings.Join(link.Parents, ", ")
+		}
+		checker.warnAs(link.URL, WARNING_KIND_PERMANENT_REDIRECT, message)
+	}
+
+	checker.progress.check(err != nil || !link.Healthy)
+	if checker.checkpoint != nil {
+		checker.checkpoint.record(checker.parents.original(link.URL.String()), Result{Link: link, Err: err})
+	}
+	checker.send(Result{Link: link, Err: err})
+}
+
+// Validates a link with a scheme other than http and https the first time it is found, reporting it as
+// broken when invalid and warning about suspicious and unknown schemes
+func (checker *Checker) checkScheme(ctx context.Context, options Options, schemes *schemeChecker, visited *visitedTracker, link *url.URL, page *url.URL) {
+	if firstVisited, claimed := visited.claim(link.String()); !claimed {
+		checker.parents.discovered(firstVisited, page.String())
+		return
+	}
+	checker.parents.discovered(link.String(), page.String())
+
+	warning, checkError := schemes.check(ctx, link)
+	if warning != "" {
+		checker.warn(page, fmt.Sprintf("%s %s on %s", warning, link, page))
+	}
+	if checkError != nil {
+		checker.report(options, Link{URL: link, Parents: []string{page.String()}, CheckedAt: time.Now()}, withCategory(ERROR_CATEGORY_INVALID_LINK, checkError))
+	}
+}
+
+// Cleans an href the same way browsers do, by trimming surrounding whitespace and removing tabs and newlines
+func cleanHref(href string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(href))
+}
+
+// Returns the URLs of the image candidates in a srcset attribute, e.g. "small.jpg 1x, large.jpg 2x"
+func parseSrcset(srcset string) []string {
+	var candidates []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			candidates = append(candidates, fields[0])
+		}
+	}
+	return candidates
+}
+
+// Initializes a new collector instance
+func (checker *Checker) getCollector(ctx context.Context, options Options, budget *linkBudget, fragments *fragmentTracker, canonicals *canonicalTracker, hreflangs *hreflangTracker, renderer *browserRenderer, queue *linkQueue) (*colly.Collector, error) {
+	// Requests are made synchronously by the workers of the queue
+	collector := colly.NewCollector(
+		colly.UserAgent(options.UserAgent),
+		colly.MaxDepth(options.Depth),
+		colly.URLFilters(
+			regexp.MustCompile("https?://.+$"),
+		),
+	)
+
+	hosts := newInternalHosts(options)
+	robots := newRobotsTracker(options)
+	if limitError := limitParallelism(ctx, collector, options, hosts, robots); limitError != nil {
+		return nil, limitError
+	}
+
+	certificates := newCertificateTracker()
+	var transport http.RoundTripper = getTransport(certificates, options)
+	if options.HeadFirst {
+		transport = &headFirstTransport{transport: transport}
+	}
+	if options.Validators != nil {
+		transport = &conditionalTransport{transport: transport, validators: options.Validators}
+	}
+	timing := newTimingTransport(transport)
+	timeout := &timeoutTransport{transport: timing, timeout: options.Timeout}
+	collector.WithTransport(newRetryTransport(timeout, options.Retries, options.RetryDelay, options.Debug))
+	// Requests are timed out by the transport, so the client timeout does not cut retries short
+	collector.SetRequestTimeout(0)
+	// Links whose body is never parsed, such as images and external links
+	checkOnly := newLinkSet()
+
+	if len(options.HeaderRules) > 0 {
+		collector.OnRequest(func(request *colly.Request) {
+			applyHeaderRules(options.HeaderRules, request.URL.Hostname(), *request.Headers)
+		})
+	}
+
+	if cookieError := setCookies(collector, options); cookieError != nil {
+		return nil, cookieError
+	}
+
+	redirects := newRedirectTracker()
+	collector.RedirectHandler = func(request *http.Request, via []*http.Request) error {
+		if redirectError := redirects.redirected(request, via, options.MaxRedirects); redirectError != nil {
+			return redirectError
+		}
+
+		// Redirects copy the previous request headers, so re-scope them to the new host
+		if len(options.HeaderRules) > 0 {
+			removeHeaderRules(options.HeaderRules, request.URL.Hostname(), request.Header)
+			if request.Header.Get("User-Agent") == "" {
+				request.Header.Set("User-Agent", options.UserAgent)
+			}
+			applyHeaderRules(options.HeaderRules, request.URL.Hostname(), request.Header)
+		}
+		return nil
+	}
+
+	rateLimits := newRateLimiter(options.HostLimits)
+	// Requests skipped before being sent are finished for the progress
+	abort := func(request *colly.Request) {
+		request.Abort()
+		checker.progress.finish()
+	}
+	collector.OnRequest(func(request *colly.Request) {
+		request.Ctx.Put(REQUESTED_CONTEXT_KEY, "1")
+		checker.progress.request()
+		if !options.IgnoreRobots && !robots.allowed(ctx, request.URL) {
+			if request.Depth <= 1 {
+				checker.warn(request.URL, fmt.Sprintf("%s is disallowed by robots.txt", request.URL))
+			}
+			options.debugf("Skipping %s, disallowed by robots.txt", request.URL)
+			abort(request)
+			return
+		}
+
+		if ctx.Err() != nil || !budget.request() {
+			abort(request)
+			return
+		}
+
+		if !options.IgnoreRobots && !robots.wait(ctx, request.URL) {
+			abort(request)
+			return
+		}
+		if !rateLimits.wait(ctx, request.URL) {
+			abort(request)
+			return
+		}
+
+		checker.parents.requested(request)
+		options.debugf("Requesting %s at depth %d", request.URL, request.Depth)
+
+		isLeaf := options.Depth > 0 && request.Depth >= options.Depth
+		if options.HeadFirst && (isLeaf || checkOnly.contains(request.URL.String())) {
+			request.Headers.Set(HEAD_FIRST_HEADER, "1")
+			request.Ctx.Put(HEAD_FIRST_CONTEXT_KEY, "1")
+		}
+		// Only bodies that are never parsed or checked can be left out by a 304 response
+		if options.Validators != nil && !options.checksContent() && (isLeaf || checkOnly.contains(request.URL.String())) {
+			request.Headers.Set(CONDITIONAL_HEADER, "1")
+		}
+	})
+
+	schemes := newSchemeChecker(options.CheckMX)
+	rewriter, rewriterError := newQueryRewriter(options.StripParams, options.SortQuery)
+	if rewriterError != nil {
+		return nil, rewriterError
+	}
+
+	// Seeds and sitemap pages are claimed when requested, discovered links before visiting them
+	visited := newVisitedTracker(options.StripQuery)
+	if checker.checkpoint != nil {
+		checker.checkpoint.claim(visited)
+	}
+	collector.OnRequest(func(request *colly.Request) {
+		visited.claim(request.URL.String())
+	})
+
+	casing := newCaseTracker()
+	if options.CheckCase {
+		collector.OnRequest(func(request *colly.Request) {
+			casing.check(request.URL.String())
+		})
+	}
+
+	var wayback *waybackClient
+	if options.Wayback {
+		wayback = newWaybackClient(options)
+	}
+
+	// On error report the reason the request failed
+	collector.OnError(func(response *colly.Response, err error) {
+		checker.progress.finish()
+		link := Link{
+			URL:       response.Request.URL,
+			Status:    response.StatusCode,
+			Parents:   checker.parents.parentsOf(response.Request),
+			Redirects: redirects.chain(checker.parents.original(response.Request.URL.String())),
+			Depth:     response.Request.Depth,
+			CrawlPath: checker.parents.crawlPath(checker.parents.original(response.Request.URL.String())),
+			CheckedAt: time.Now(),
+		}
+		link.Latency, link.TimeToFirstByte = timing.latency(checker.parents.original(response.Request.URL.String()))
+
+		isUnhealthy := !options.HealthyCodes.Contains(link.Status) && !options.WarningCodes.Contains(link.Status)
+		if wayback != nil && isUnhealthy && isDeadLink(link.Status, err) {
+			snapshot, lookupError := wayback.snapshot(ctx, link.URL.String())
+			if lookupError != nil {
+				checker.warn(link.URL, fmt.Sprintf("Could not look up an archived snapshot of %s: %s", link.URL, lookupError))
+			}
+			link.ArchivedURL = snapshot
+		}
+
+		checker.report(options, link, err)
+	})
+
+	// Checks a link found on a page and follows it at the given depth, unless the link is an asset or an
+	// external link that is only checked
+	discoverAt := func(element *colly.HTMLElement, rawLink string, isAsset bool, depth int) {
+		if ctx.Err() != nil || !budget.discovering() {
+			return
+		}
+		// Pages on external hosts are only checked, unless external links are crawled
+		if options.External != EXTERNAL_CRAWL && !hosts.isInternal(element.Request.URL) {
+			return
+		}
+		if !budget.following(element.Request.URL.String()) {
+			return
+		}
+
+		link := cleanHref(rawLink)
+		if options.StripQuery {
+			link = stripQuery(link)
+		}
+
+		if options.ReportMalformedHrefs && link != rawLink {
+			checker.warn(element.Request.URL, fmt.Sprintf("Malformed href %q on %s", rawLink, element.Request.URL))
+		}
+
+		absoluteLink := element.Request.AbsoluteURL(link)
+		if absoluteLink == "" {
+			return
+		}
+		if rewriter != nil {
+			absoluteLink = rewriter.rewrite(absoluteLink)
+		}
+
+		isExternal := false
+		if parsedLink, parseError := url.Parse(absoluteLink); parseError == nil {
+			if parsedLink.Scheme != "http" && parsedLink.Scheme != "https" {
+				if options.CheckSchemes {
+					checker.checkScheme(ctx, options, schemes, visited, parsedLink, element.Request.URL)
+				}
+				return
+			}
+			if hosts.isDisallowed(parsedLink) {
+				options.debugf("Skipping %s found on %s, its host is disallowed", absoluteLink, element.Request.URL)
+				return
+			}
+			isExternal = !hosts.isInternal(parsedLink)
+		}
+		if (isExternal && options.External == EXTERNAL_SKIP) || !isIncluded(options, absoluteLink) {
+			options.debugf("Skipping %s found on %s, it is external or excluded", absoluteLink, element.Request.URL)
+			return
+		}
+		if options.Hooks.OnLinkDiscovered != nil {
+			if parsedLink, parseError := url.Parse(absoluteLink); parseError == nil && !options.Hooks.OnLinkDiscovered(parsedLink, element.Request.URL) {
+				options.debugf("Skipping %s found on %s, it was rejected by the OnLinkDiscovered hook", absoluteLink, element.Request.URL)
+				return
+			}
+		}
+
+		if options.CheckCase {
+			if firstSeen, inconsistent := casing.check(absoluteLink); inconsistent {
+				checker.warn(element.Request.URL, fmt.Sprintf("Case-inconsistent link %s on %s, previously seen as %s", absoluteLink, element.Request.URL, firstSeen))
+				return
+			}
+		}
+
+		// Links written differently than an already visited URL of the same page are only recorded as found
+		firstVisited, claimed := visited.claim(absoluteLink)
+		if firstVisited != absoluteLink {
+			checker.parents.discovered(firstVisited, element.Request.URL.String())
+			return
+		}
+
+		checker.parents.discovered(absoluteLink, element.Request.URL.String())
+		if isAsset || (isExternal && options.External == EXTERNAL_CHECK) {
+			checkOnly.add(absoluteLink)
+		}
+		// Links claimed before are already queued or requested
+		if !claimed {
+			return
+		}
+		// Links beyond the max depth are rejected right away rather than queued
+		if options.Depth > 0 && depth > options.Depth {
+			options.debugf("Not visiting %s found on %s: %s", absoluteLink, element.Request.URL, colly.ErrMaxDepth)
+			visited.release(absoluteLink)
+			return
+		}
+		checker.parents.queued(absoluteLink, element.Request.URL.String())
+		queue.push(queuedLink{Link: absoluteLink, Depth: depth, Page: element.Request.URL.String(), Claimed: claimed})
+		options.debugf("Queued %s found on %s", absoluteLink, element.Request.URL)
+	}
+	// Links of a page are one level deeper than the page
+	discover := func(element *colly.HTMLElement, rawLink string, isAsset bool) {
+		discoverAt(element, rawLink, isAsset, element.Request.Depth+1)
+	}
+
+	// Failures of requests that reached the OnRequest callbacks are reported by OnError, other errors
+	// mean the link was not requested, e.g. because it was already visited
+	queue.handle(func(queued queuedLink) {
+		if checker.checkpoint != nil && checker.checkpoint.wasChecked(queued.Link) {
+			return
+		}
+		request, requestError := queued.request(collector)
+		if requestError == nil {
+			requestError = request.Do()
+		}
+		if requestError == nil || (request != nil && request.Ctx.Get(REQUESTED_CONTEXT_KEY) != "") {
+			return
+		}
+
+		switch {
+		case queued.Seed:
+			seedURL, _ := url.Parse(queued.Link)
+			checker.send(Result{Link: Link{URL: seedURL, CheckedAt: time.Now()}, Err: requestError})
+		case requestError != colly.ErrAlreadyVisited && queued.Claimed:
+			options.debugf("Not visiting %s found on %s: %s", queued.Link, queued.Page, requestError)
+			visited.release(queued.Link)
+		}
+	})
+
+	collector.OnHTML("a[href]", func(element *colly.HTMLElement) {
+		if options.CheckFragments {
+			fragments.referenced(element, cleanHref(element.Attr("href")))
+		}
+		discover(element, element.Attr("href"), false)
+	})
+
+	if options.CheckFragments {
+		collector.OnHTML("html", func(element *colly.HTMLElement) {
+			page := element.Request.URL.String()
+			fragments.found(element, page, checker.parents.original(page))
+		})
+	}
+
+	if options.CheckHreflang {
+		collector.OnHTML("html", func(element *colly.HTMLElement) {
+			page := element.Request.URL.String()
+			alternates, warnings := hreflangs.found(element, page, checker.parents.original(page))
+			for _, warning := range warnings {
+				checker.warn(element.Request.URL, warning)
+			}
+			for _, alternate := range alternates {
+				discover(element, alternate, false)
+			}
+		})
+	}
+
+	if options.CheckCanonical {
+		collector.OnHTML(CANONICAL_SELECTOR, func(element *colly.HTMLElement) {
+			canonicals.canonical(element, options.StripQuery)
+		})
+	}
+
+	if options.CheckCanonical || options.FollowMetaRefresh {
+		collector.OnHTML(META_REFRESH_SELECTOR, func(element *colly.HTMLElement) {
+			if !strings.EqualFold(element.Attr("http-equiv"), "refresh") {
+				return
+			}
+			delay, target, ok := parseMetaRefresh(element.Attr("content"))
+			if !ok || target == "" {
+				return
+			}
+			if absoluteTarget := element.Request.AbsoluteURL(cleanHref(target)); options.CheckCanonical && absoluteTarget != "" {
+				canonicals.refresh(element.Request.URL, absoluteTarget, delay)
+			}
+			// Refreshes are followed like redirects, without going one level deeper
+			if options.FollowMetaRefresh {
+				discoverAt(element, target, false, element.Request.Depth)
+			}
+		})
+	}
+
+	if options.CheckAssets {
+		for selector, attribute := range ASSET_SELECTORS {
+			attribute := attribute
+			collector.OnHTML(selector, func(element *colly.HTMLElement) {
+				discover(element, element.Attr(attribute), element.Name != "iframe")
+			})
+		}
+
+		for _, selector := range SRCSET_SELECTORS {
+			collector.OnHTML(selector, func(element *colly.HTMLElement) {
+				for _, candidate := range parseSrcset(element.Attr("srcset")) {
+					discover(element, candidate, true)
+				}
+			})
+		}
+	}
+
+	collector.OnResponse(func(response *colly.Response) {
+		checker.progress.finish()
+		link := Link{
+			URL:       response.Request.URL,
+			Status:    response.StatusCode,
+			Parents:   checker.parents.parentsOf(response.Request),
+			Redirects: redirects.chain(checker.parents.original(response.Request.URL.String())),
+			Depth:     response.Request.Depth,
+			CrawlPath: checker.parents.crawlPath(checker.parents.original(response.Request.URL.String())),
+			CheckedAt: time.Now(),
+		}
+		link.Latency, link.TimeToFirstByte = timing.latency(checker.parents.original(response.Request.URL.String()))
+
+		if expiry, ok := certificates.expiry(link.URL.Hostname()); ok && link.URL.Scheme == "https" {
+			link.CertExpiryDays = int(time.Until(expiry).Hours() / 24)
+			link.HasCertificate = true
+
+			if options.CertExpiryWarn > 0 && link.CertExpiryDays <= options.CertExpiryWarn && certificates.shouldWarn(link.URL.Hostname()) {
+				checker.warn(link.URL, fmt.Sprintf("Certificate for %s expires in %d days (%s)", link.URL.Hostname(), link.CertExpiryDays, expiry.Format("2006-01-02")))
+			}
+		}
+
+		if options.LocalePattern != nil {
+			contentLanguage := response.Headers.Get("Content-Language")
+			if locale, mismatch := checkContentLanguage(options.LocalePattern, link.URL, contentLanguage); mismatch {
+				checker.warn(link.URL, fmt.Sprintf("Content-Language %q of %s does not match its locale %q", contentLanguage, link.URL, locale))
+			}
+		}
+
+		if link.Status != http.StatusNotModified && (response.Ctx.Get(HEAD_FIRST_CONTEXT_KEY) == "" || len(response.Body) > 0) {
+			link.Size = len(response.Body)
+			link.HasSize = true
+		}
+
+		var contentError error
+		if options.checksContent() && options.HealthyCodes.Contains(link.Status) {
+			contentError = checkContent(options, response)
+		}
+		if contentError == nil && len(options.LinkValidators) > 0 && options.HealthyCodes.Contains(link.Status) {
+			contentError = validateLink(options.LinkValidators, ValidatedResponse{Link: link, Headers: *response.Headers, Body: response.Body})
+		}
+
+		checker.report(options, link, contentError)
+	})
+
+	// Replace the HTML of pages whose links are followed with their rendered HTML, before colly parses it
+	if renderer != nil {
+		collector.OnResponse(func(response *colly.Response) {
+			isLeaf := options.Depth > 0 && response.Request.Depth >= options.Depth
+			isFollowed := options.External == EXTERNAL_CRAWL || hosts.isInternal(response.Request.URL)
+			isHTML := strings.Contains(strings.ToLower(response.Headers.Get("Content-Type")), "html")
+			if isLeaf || !isFollowed || !isHTML || checkOnly.contains(response.Request.URL.String()) || ctx.Err() != nil {
+				return
+			}
+
+			page := response.Request.URL.String()
+			rendered, renderError := renderer.render(ctx, page, collector.Cookies(page))
+			if renderError != nil {
+				checker.warn(response.Request.URL, fmt.Sprintf("Could not render %s, checking the links of its HTML as served: %s", page, renderError))
+				return
+			}
+			response.Body = rendered
+		})
+	}
+
+	return collector, nil
+}
@@ -0,0 +1,28 @@
+package linkhealth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		name   string
+		srcset string
+		want   []string
+	}{
+		{"empty", "", nil},
+		{"single URL", "image.jpg", []string{"image.jpg"}},
+		{"pixel densities", "small.jpg 1x, large.jpg 2x", []string{"small.jpg", "large.jpg"}},
+		{"widths", "small.jpg 480w,medium.jpg 800w,  large.jpg 1200w", []string{"small.jpg", "medium.jpg", "large.jpg"}},
+		{"surrounding whitespace", "\n  small.jpg 1x,\n  large.jpg 2x\n", []string{"small.jpg", "large.jpg"}},
+		{"empty candidates", "small.jpg 1x, , large.jpg 2x,", []string{"small.jpg", "large.jpg"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseSrcset(test.srcset); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSrcset(%q) = %q, want %q", test.srcset, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package linkhealth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How often the state of the crawl is written to the checkpoint, see Options.Checkpoint
+const CHECKPOINT_INTERVAL = 30 * time.Second
+
+// The state of an interrupted crawl
+type checkpointState struct {
+	Saved time.Time `json:"saved"`
+	// Starting URLs of the crawl, a checkpoint is only resumed by a crawl of the same URLs
+	URLs    []string            `json:"urls"`
+	Checked []checkpointLink    `json:"checked"`
+	Pending []queuedLink        `json:"pending"`
+	Parents map[string][]string `json:"parents"`
+}
+
+// A checked link, reported again when the crawl is resumed
+type checkpointLink struct {
+	StoredLink
+	// The URL the link was found as, the result is for the URL it redirected to
+	Link      string    `json:"link"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Records the checked links of a crawl and periodically writes them to the checkpoint file together with
+// the links still queued, so an interrupted crawl continues where it left off when run again with the
+// same checkpoint. Checked links are not requested again, and links that were being requested are
+// requested again.
+type checkpointer struct {
+	path    string
+	urls    []string
+	lock    sync.Mutex
+	checked []checkpointLink
+	// Links checked by a previous run of the crawl, by the URL they were found as
+	restored map[string]bool
+	pending  []queuedLink
+	parents  map[string][]string
+}
+
+// Loads the checkpoint at the path when it exists
+func newCheckpointer(path string, urls []*url.URL) (*checkpointer, error) {
+	saved := &checkpointer{path: path, restored: make(map[string]bool)}
+	for _, targetURL := range urls {
+		saved.urls = append(saved.urls, targetURL.String())
+	}
+
+	content, readError := ioutil.ReadFile(path)
+	if os.IsNotExist(readError) {
+		return saved, nil
+	}
+	if readError != nil {
+		return nil, fmt.Errorf("Could not read checkpoint %s: %s", path, readError)
+	}
+
+	var state checkpointState
+	if decodeError := json.Unmarshal(content, &state); decodeError != nil {
+		return nil, fmt.Errorf("Invalid checkpoint %s: %s", path, decodeError)
+	}
+	if !sameURLs(state.URLs, saved.urls) {
+		return nil, fmt.Errorf("Checkpoint %s was written by a crawl from other URLs, remove it to start over", path)
+	}
+
+	saved.checked = state.Checked
+	for _, link := range state.Checked {
+		saved.restored[link.Link] = true
+	}
+	saved.pending = state.Pending
+	saved.parents = state.Parents
+	return saved, nil
+}
+
+func sameURLs(saved []string, urls []string) bool {
+	if len(saved) != len(urls) {
+		return false
+	}
+	saved = append([]string(nil), saved...)
+	urls = append([]string(nil), urls...)
+	sort.Strings(saved)
+	sort.Strings(urls)
+	for index := range saved {
+		if saved[index] != urls[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the crawl is resumed from a checkpoint
+func (saved *checkpointer) resuming() bool {
+	return len(saved.checked) > 0 || len(saved.pending) > 0
+}
+
+// Whether the link was checked before the crawl was resumed
+func (saved *checkpointer) wasChecked(link string) bool {
+	return saved.restored[link]
+}
+
+// Returns the results of the links checked before the crawl was resumed
+func (saved *checkpointer) results() []Result {
+	results := make([]Result, 0, len(saved.checked))
+	for _, link := range saved.checked {
+		linkURL, parseError := url.Parse(link.URL)
+		if parseError != nil {
+			continue
+		}
+		result := Result{Link: Link{URL: linkURL, Status: link.Status, Healthy: link.Healthy, CheckedAt: link.CheckedAt}}
+		if link.Error != "" {
+			result.Err = withCategory(link.Category, errors.New(link.Error))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Claims the checked and pending links, so they are not queued again when found
+func (saved *checkpointer) claim(visited *visitedTracker) {
+	for _, link := range saved.checked {
+		visited.claim(link.Link)
+	}
+	for _, queued := range saved.pending {
+		visited.claim(queued.Link)
+	}
+}
+
+// Writes the checkpoint every CHECKPOINT_INTERVAL until the returned function is called
+func (saved *checkpointer) autosave(queue *linkQueue, parents *parentTracker, failed func(err error)) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(CHECKPOINT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if saveError := saved.save(queue, parents); saveError != nil {
+					failed(saveError)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// Records a checked link, found as the given URL
+func (saved *checkpointer) record(found string, result Result) {
+	saved.lock.Lock()
+	defer saved.lock.Unlock()
+	saved.checked = append(saved.checked, checkpointLink{StoredLink: NewStoredLink(result), Link: found, CheckedAt: result.CheckedAt})
+}
+
+// Writes the checkpoint, replacing the previous one only once it is completely written
+func (saved *checkpointer) save(queue *linkQueue, parents *parentTracker) error {
+	// The queue is saved before the checked links, so links checked in between are in both rather than in neither
+	pending, snapshotError := queue.snapshot()
+	if snapshotError != nil {
+		return fmt.Errorf("Could not write checkpoint: %s", snapshotError)
+	}
+	saved.lock.Lock()
+	state := checkpointState{
+		Saved:   time.Now(),
+		URLs:    saved.urls,
+		Checked: append([]checkpointLink(nil), saved.checked...),
+		Pending: pending,
+		Parents: parents.snapshot(),
+	}
+	saved.lock.Unlock()
+
+	content, encodeError := json.Marshal(state)
+	if encodeError != nil {
+		return fmt.Errorf("Could not write checkpoint: %s", encodeError)
+	}
+	temporary := saved.path + ".tmp"
+	if writeError := ioutil.WriteFile(temporary, content, 0644); writeError != nil {
+		return fmt.Errorf("Could not write checkpoint: %s", writeError)
+	}
+	if renameError := os.Rename(temporary, saved.path); renameError != nil {
+		return fmt.Errorf("Could not write checkpoint: %s", renameError)
+	}
+	return nil
+}
+
+// Removes the checkpoint once the crawl finished, so the next crawl starts over
+func (saved *checkpointer) remove() error {
+	if removeError := os.Remove(saved.path); removeError != nil && !os.IsNotExist(removeError) {
+		return fmt.Errorf("Could not remove checkpoint: %s", removeError)
+	}
+	return nil
+}
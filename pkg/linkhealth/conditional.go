@@ -0,0 +1,90 @@
+package linkhealth
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Internal header marking requests that may be made as conditional requests, removed before the request is sent
+const CONDITIONAL_HEADER = "X-Simple-Link-Health-Conditional"
+
+// The validators of a response, sent back with conditional requests so unchanged links respond with
+// 304 Not Modified and without a body
+type Validator struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// The validators of the links seen by previous crawls, by URL, safe for concurrent use. See Options.Validators
+type ResponseValidators struct {
+	lock       sync.RWMutex
+	validators map[string]Validator
+}
+
+func NewResponseValidators() *ResponseValidators {
+	return &ResponseValidators{validators: make(map[string]Validator)}
+}
+
+func (validators *ResponseValidators) Get(link string) (Validator, bool) {
+	validators.lock.RLock()
+	defer validators.lock.RUnlock()
+	validator, found := validators.validators[link]
+	return validator, found
+}
+
+func (validators *ResponseValidators) Set(link string, validator Validator) {
+	validators.lock.Lock()
+	validators.validators[link] = validator
+	validators.lock.Unlock()
+}
+
+// Returns a copy of every validator, by URL
+func (validators *ResponseValidators) All() map[string]Validator {
+	validators.lock.RLock()
+	defer validators.lock.RUnlock()
+	all := make(map[string]Validator, len(validators.validators))
+	for link, validator := range validators.validators {
+		all[link] = validator
+	}
+	return all
+}
+
+// Sends marked requests with the If-None-Match and If-Modified-Since headers of the validators previously
+// seen for their URL, and records the validators of successful responses. Each redirect is validated for
+// its own URL, since redirects copy the headers of the previous request.
+type conditionalTransport struct {
+	transport  http.RoundTripper
+	validators *ResponseValidators
+}
+
+func (conditional *conditionalTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header.Get(CONDITIONAL_HEADER) == "" {
+		return conditional.transport.RoundTrip(request)
+	}
+
+	link := request.URL.String()
+	request = request.Clone(request.Context())
+	request.Header.Del(CONDITIONAL_HEADER)
+	request.Header.Del("If-None-Match")
+	request.Header.Del("If-Modified-Since")
+	if validator, found := conditional.validators.Get(link); found {
+		if validator.ETag != "" {
+			request.Header.Set("If-None-Match", validator.ETag)
+		}
+		if validator.LastModified != "" {
+			request.Header.Set("If-Modified-Since", validator.LastModified)
+		}
+	}
+
+	response, roundTripError := conditional.transport.RoundTrip(request)
+	if roundTripError != nil {
+		return nil, roundTripError
+	}
+	if response.StatusCode == http.StatusOK {
+		validator := Validator{ETag: response.Header.Get("ETag"), LastModified: response.Header.Get("Last-Modified")}
+		if validator.ETag != "" || validator.LastModified != "" {
+			conditional.validators.Set(link, validator)
+		}
+	}
+	return response, nil
+}
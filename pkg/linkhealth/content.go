@@ -0,0 +1,88 @@
+package linkhealth
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/gocolly/colly"
+)
+
+// Compiles regular expressions matched against response bodies, see Options.ExpectText
+func CompileTextPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		textPattern, compileError := regexp.Compile(pattern)
+		if compileError != nil {
+			return nil, fmt.Errorf("Invalid text pattern %q: %s", pattern, compileError)
+		}
+		compiled = append(compiled, textPattern)
+	}
+	return compiled, nil
+}
+
+// Checks whether any content check is enabled
+func (options Options) checksContent() bool {
+	return len(options.ExpectText) > 0 || len(options.RejectText) > 0 || options.MinContentLength > 0 || len(options.ContentTypes) > 0
+}
+
+// Checks the content of a response with a healthy status, returning why the link is a soft failure, such as a
+// "Page not found" page served with 200. The text checks only apply to text responses, and only the
+// Content-Type is checked for responses to HEAD requests.
+func checkContent(options Options, response *colly.Response) error {
+	contentType := response.Headers.Get("Content-Type")
+	if len(options.ContentTypes) > 0 && !isAllowedContentType(options.ContentTypes, contentType) {
+		return withCategory(ERROR_CATEGORY_CONTENT, fmt.Errorf("Content-Type %q is not allowed", contentType))
+	}
+	if response.Ctx.Get(HEAD_FIRST_CONTEXT_KEY) != "" && len(response.Body) == 0 {
+		return nil
+	}
+
+	if options.MinContentLength > 0 && len(response.Body) < options.MinContentLength {
+		return withCategory(ERROR_CATEGORY_CONTENT, fmt.Errorf("Response is %d bytes, shorter than the minimum of %d bytes", len(response.Body), options.MinContentLength))
+	}
+
+	if !isTextContentType(contentType) {
+		return nil
+	}
+	for _, pattern := range options.ExpectText {
+		if !pattern.Match(response.Body) {
+			return withCategory(ERROR_CATEGORY_CONTENT, fmt.Errorf("Response does not contain the expected text %q", pattern))
+		}
+	}
+	for _, pattern := range options.RejectText {
+		if match := pattern.Find(response.Body); match != nil {
+			return withCategory(ERROR_CATEGORY_CONTENT, fmt.Errorf("Response contains the rejected text %q", match))
+		}
+	}
+	return nil
+}
+
+// Matches the media type of the Content-Type against the allowed types, e.g. text/html or image/*
+func isAllowedContentType(allowed []string, contentType string) bool {
+	mediaType, _, parseError := mime.ParseMediaType(contentType)
+	if parseError != nil {
+		return false
+	}
+	for _, allowedType := range allowed {
+		allowedType = strings.ToLower(strings.TrimSpace(allowedType))
+		if allowedType == mediaType || (strings.HasSuffix(allowedType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowedType, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether the body is text worth matching, such as HTML, XML or JSON. Responses without a
+// Content-Type are assumed to be text
+func isTextContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, parseError := mime.ParseMediaType(contentType)
+	if parseError != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml") || strings.HasSuffix(mediaType, "json") || mediaType == "application/javascript"
+}
@@ -0,0 +1,52 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Matches a leading locale path segment such as /es/ or /pt-br/
+const DEFAULT_LOCALE_PATTERN = `^/([a-zA-Z]{2}(?:[-_][a-zA-Z]{2,4})?)(?:/|$)`
+
+// Compiles the locale pattern, which must contain a capture group extracting the locale from the URL path
+func CompileLocalePattern(pattern string) (*regexp.Regexp, error) {
+	localePattern, compileError := regexp.Compile(pattern)
+	if compileError != nil {
+		return nil, fmt.Errorf("Invalid locale pattern %q: %s", pattern, compileError)
+	}
+
+	if localePattern.NumSubexp() < 1 {
+		return nil, fmt.Errorf("Locale pattern %q must contain a capture group for the locale", pattern)
+	}
+
+	return localePattern, nil
+}
+
+// Compares the locale in the URL path against the Content-Language header.
+// Returns the URL locale and whether it mismatched, links without a locale segment or header never mismatch.
+func checkContentLanguage(localePattern *regexp.Regexp, link *url.URL, contentLanguage string) (string, bool) {
+	match := localePattern.FindStringSubmatch(link.Path)
+	if match == nil || match[1] == "" || strings.TrimSpace(contentLanguage) == "" {
+		return "", false
+	}
+
+	locale := normalizeLanguageTag(match[1])
+	for _, language := range strings.Split(contentLanguage, ",") {
+		language = normalizeLanguageTag(language)
+		if language == locale || primaryLanguage(language) == locale || language == primaryLanguage(locale) {
+			return locale, false
+		}
+	}
+
+	return locale, true
+}
+
+func normalizeLanguageTag(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+func primaryLanguage(tag string) string {
+	return strings.SplitN(tag, "-", 2)[0]
+}
@@ -0,0 +1,86 @@
+package linkhealth
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// Prefix of cookie file lines holding HttpOnly cookies, which would otherwise be comments
+const HTTP_ONLY_COOKIE_PREFIX = "#HttpOnly_"
+
+// Loads cookies from a Netscape cookie file, the format used by curl, wget and browser extensions.
+// Every cookie is returned with its domain set, cookies limited to a single host apply to its subdomains too.
+func LoadCookieFile(cookiePath string) ([]*http.Cookie, error) {
+	file, openError := os.Open(cookiePath)
+	if openError != nil {
+		return nil, openError
+	}
+	defer file.Close()
+
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, HTTP_ONLY_COOKIE_PREFIX)
+		line = strings.TrimPrefix(line, HTTP_ONLY_COOKIE_PREFIX)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// domain, include subdomains, path, secure, expiry, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("Invalid cookie on line %d of cookie file %s, expected 7 tab separated fields", lineNumber, cookiePath)
+		}
+		expiry, expiryError := strconv.ParseInt(fields[4], 10, 64)
+		if expiryError != nil {
+			return nil, fmt.Errorf("Invalid cookie expiry on line %d of cookie file %s", lineNumber, cookiePath)
+		}
+
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		// An expiry of 0 marks session cookies
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, scanner.Err()
+}
+
+// Adds the cookies to the collector's cookie jar. Cookies with a domain are sent to that domain,
+// other cookies to the hosts of the starting URLs.
+func setCookies(collector *colly.Collector, options Options) error {
+	for _, cookie := range options.Cookies {
+		var sites []string
+		if cookie.Domain != "" {
+			domain := strings.TrimPrefix(cookie.Domain, ".")
+			sites = []string{"https://" + domain + "/", "http://" + domain + "/"}
+		} else {
+			for _, targetURL := range options.URLs {
+				sites = append(sites, targetURL.Scheme+"://"+targetURL.Host+"/")
+			}
+		}
+
+		for _, site := range sites {
+			if cookieError := collector.SetCookies(site, []*http.Cookie{cookie}); cookieError != nil {
+				return cookieError
+			}
+		}
+	}
+	return nil
+}
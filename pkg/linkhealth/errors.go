@@ -0,0 +1,112 @@
+package linkhealth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Categories of failed links, separating links to hosts that are gone from flaky servers
+const (
+	// The host name does not resolve, the domain is most likely gone
+	ERROR_CATEGORY_DNS = "dns"
+	// Nothing listens on the port of the host
+	ERROR_CATEGORY_CONNECTION_REFUSED = "connection_refused"
+	// The server closed the connection before responding
+	ERROR_CATEGORY_CONNECTION_RESET = "connection_reset"
+	// The TLS handshake failed or the certificate is not trusted
+	ERROR_CATEGORY_TLS = "tls"
+	// The server did not respond within the timeout
+	ERROR_CATEGORY_TIMEOUT = "timeout"
+	// The redirects looped or exceeded Options.MaxRedirects
+	ERROR_CATEGORY_TOO_MANY_REDIRECTS = "too_many_redirects"
+	// The server responded with an unhealthy status
+	ERROR_CATEGORY_HTTP_STATUS = "http_status"
+	// The server responded with a healthy status but unexpected content, such as a soft 404 page
+	ERROR_CATEGORY_CONTENT = "content"
+	// The link itself is broken, such as an invalid mailto link or a missing anchor
+	ERROR_CATEGORY_INVALID_LINK = "invalid_link"
+	// A custom check of Options.LinkValidators failed
+	ERROR_CATEGORY_CUSTOM = "custom"
+	// Any other network error
+	ERROR_CATEGORY_NETWORK = "network"
+)
+
+// Every category of failed links
+var ERROR_CATEGORIES = []string{
+	ERROR_CATEGORY_DNS,
+	ERROR_CATEGORY_CONNECTION_REFUSED,
+	ERROR_CATEGORY_CONNECTION_RESET,
+	ERROR_CATEGORY_TLS,
+	ERROR_CATEGORY_TIMEOUT,
+	ERROR_CATEGORY_TOO_MANY_REDIRECTS,
+	ERROR_CATEGORY_HTTP_STATUS,
+	ERROR_CATEGORY_CONTENT,
+	ERROR_CATEGORY_INVALID_LINK,
+	ERROR_CATEGORY_CUSTOM,
+	ERROR_CATEGORY_NETWORK,
+}
+
+// An error carrying its category, for failures that cannot be recognized by their type
+type categorizedError struct {
+	category string
+	err      error
+}
+
+func withCategory(category string, err error) error {
+	return &categorizedError{category: category, err: err}
+}
+
+func (categorized *categorizedError) Error() string {
+	return categorized.err.Error()
+}
+
+func (categorized *categorizedError) Unwrap() error {
+	return categorized.err
+}
+
+// Returns the category of a failed request, one of the ERROR_CATEGORY constants.
+// Returns an empty category when the request did not fail.
+func CategorizeError(err error, status int) string {
+	if err == nil {
+		if status != 0 {
+			return ERROR_CATEGORY_HTTP_STATUS
+		}
+		return ""
+	}
+
+	var categorized *categorizedError
+	var dnsError *net.DNSError
+	var recordHeaderError tls.RecordHeaderError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+	var netError net.Error
+	switch {
+	case errors.As(err, &categorized):
+		return categorized.category
+	case status != 0:
+		return ERROR_CATEGORY_HTTP_STATUS
+	case errors.As(err, &dnsError):
+		if dnsError.IsTimeout {
+			return ERROR_CATEGORY_TIMEOUT
+		}
+		return ERROR_CATEGORY_DNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ERROR_CATEGORY_CONNECTION_REFUSED
+	case errors.Is(err, syscall.ECONNRESET):
+		return ERROR_CATEGORY_CONNECTION_RESET
+	case errors.As(err, &recordHeaderError), errors.As(err, &unknownAuthorityError), errors.As(err, &hostnameError), errors.As(err, &certificateInvalidError):
+		return ERROR_CATEGORY_TLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+		return ERROR_CATEGORY_TIMEOUT
+	case strings.Contains(err.Error(), "tls: "), strings.Contains(err.Error(), "x509: "):
+		// Handshake alerts and other TLS errors are not exported as types
+		return ERROR_CATEGORY_TLS
+	}
+	return ERROR_CATEGORY_NETWORK
+}
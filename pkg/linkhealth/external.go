@@ -0,0 +1,137 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// Ways of handling external links, i.e. links to hosts other than the ones of the starting URLs
+const (
+	// Check external links without following the links on them
+	EXTERNAL_CHECK = "check"
+	// Ignore external links
+	EXTERNAL_SKIP = "skip"
+	// Crawl external links like internal links
+	EXTERNAL_CRAWL = "crawl"
+)
+
+// The hosts of the starting URLs and the allowed domains, links to any other host are external.
+// Links to disallowed domains are neither internal nor external, they are skipped.
+type internalHosts struct {
+	seeds      map[string]bool
+	allowed    []*regexp.Regexp
+	disallowed []*regexp.Regexp
+}
+
+func newInternalHosts(options Options) internalHosts {
+	hosts := internalHosts{
+		seeds:      make(map[string]bool),
+		allowed:    compileHostPatterns(options.AllowedDomains),
+		disallowed: compileHostPatterns(options.DisallowedDomains),
+	}
+	for _, targetURL := range options.URLs {
+		hosts.seeds[targetURL.Host] = true
+	}
+	return hosts
+}
+
+func compileHostPatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		compiled = append(compiled, regexp.MustCompile(hostPatternRegexp(strings.ToLower(pattern))))
+	}
+	return compiled
+}
+
+func matchesHostPattern(patterns []*regexp.Regexp, link *url.URL) bool {
+	host := strings.ToLower(link.Host)
+	for _, pattern := range patterns {
+		if pattern.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (hosts internalHosts) isInternal(link *url.URL) bool {
+	if hosts.isDisallowed(link) {
+		return false
+	}
+	return hosts.seeds[link.Host] || matchesHostPattern(hosts.allowed, link)
+}
+
+func (hosts internalHosts) isDisallowed(link *url.URL) bool {
+	return matchesHostPattern(hosts.disallowed, link)
+}
+
+func isValidExternalPolicy(policy string) bool {
+	return policy == EXTERNAL_CHECK || policy == EXTERNAL_SKIP || policy == EXTERNAL_CRAWL
+}
+
+// Limits internal hosts to Threads and all external hosts together to ExternalThreads parallel requests,
+// unless a host limit with a parallelism matches the host first. Requests wait a random delay of up to a
+// second, except on starting hosts whose robots.txt sets a Crawl-delay, which is waited instead.
+func limitParallelism(ctx context.Context, collector *colly.Collector, options Options, hosts internalHosts, robots *robotsTracker) error {
+	if !isValidExternalPolicy(options.External) {
+		return fmt.Errorf("Invalid external value %q, expected one of %s, %s, %s", options.External, EXTERNAL_CHECK, EXTERNAL_SKIP, EXTERNAL_CRAWL)
+	}
+
+	var rules []*colly.LimitRule
+	var parallelismLimits []*regexp.Regexp
+	for _, limit := range options.HostLimits {
+		if limit.Parallelism > 0 {
+			parallelismLimits = append(parallelismLimits, regexp.MustCompile(hostPatternRegexp(limit.Host)))
+			rules = append(rules, &colly.LimitRule{
+				DomainRegexp: hostPatternRegexp(limit.Host),
+				Parallelism:  limit.Parallelism,
+				RandomDelay:  1 * time.Second,
+			})
+		}
+	}
+	crawlDelays := make(map[string]time.Duration)
+	if !options.IgnoreRobots {
+		for _, seed := range options.URLs {
+			if delay := robots.crawlDelay(ctx, seed); delay > crawlDelays[seed.Host] {
+				crawlDelays[seed.Host] = delay
+			}
+		}
+	}
+	for host := range hosts.seeds {
+		rule := &colly.LimitRule{
+			DomainRegexp: "^" + regexp.QuoteMeta(host) + "$",
+			Parallelism:  options.Threads,
+			RandomDelay:  1 * time.Second,
+		}
+		// Colly waits the delay after each request while holding its slot, which only spaces requests by
+		// the delay without parallelism. Hosts matching a host limit first keep waiting in the robots tracker.
+		if delay := crawlDelays[host]; delay > 0 && !matchesHostPattern(parallelismLimits, &url.URL{Host: host}) {
+			rule.Parallelism = 1
+			rule.Delay = delay
+			rule.RandomDelay = 0
+			robots.delayedByCollector(host)
+			options.debugf("Waiting %s between requests to %s, the Crawl-delay of its robots.txt", delay, host)
+		}
+		rules = append(rules, rule)
+	}
+	for _, pattern := range hosts.allowed {
+		rules = append(rules, &colly.LimitRule{
+			DomainRegexp: pattern.String(),
+			Parallelism:  options.Threads,
+			RandomDelay:  1 * time.Second,
+		})
+	}
+	// Rules are matched in order, so the catch all rule for external hosts comes last
+	rules = append(rules, &colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: options.ExternalThreads,
+		RandomDelay: 1 * time.Second,
+	})
+
+	return collector.Limits(rules)
+}
@@ -0,0 +1,239 @@
+package linkhealth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Extensions of the files scanned for links, see ExtractFileLinks
+var LINK_FILE_EXTENSIONS = []string{".md", ".markdown", ".html", ".htm", ".rst"}
+
+var (
+	// [text](target "title") and ![alt](target)
+	MARKDOWN_INLINE_LINK = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)`)
+	// [label]: target
+	MARKDOWN_REFERENCE_LINK = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*<?([^>\s]+)>?`)
+	// <https://autolink>
+	AUTOLINK = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	// href and src attributes, also found in Markdown
+	HTML_LINK_ATTRIBUTE = regexp.MustCompile(`(?i)\s(?:href|src)\s*=\s*["']([^"']+)["']`)
+	// `text <target>`_ and `text <target>`__
+	RST_INLINE_LINK = regexp.MustCompile("`[^`<]*<([^>`]+)>`__?")
+	// .. _label: target
+	RST_TARGET = regexp.MustCompile(`^\s*\.\.\s+_[^:]+:\s+(\S+)`)
+)
+
+// A link found in a local file
+type FileLink struct {
+	File   string
+	Line   int
+	Target string
+}
+
+// How links found in local files are checked
+type FileCheckOptions struct {
+	// Skip remote URLs, only checking links to local files
+	Offline bool
+	// Directory links starting with / are relative to, such as the root of a repository
+	Root string
+}
+
+// The outcome of checking a link found in a local file, Err is set when the link is broken
+type FileLinkResult struct {
+	FileLink
+	Status int
+	Err    error
+}
+
+// Returns the files to scan under the paths, walking directories for files with one of LINK_FILE_EXTENSIONS
+func FindLinkFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, statError := os.Stat(path)
+		if statError != nil {
+			return nil, statError
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		walkError := filepath.Walk(path, func(file string, info os.FileInfo, walkError error) error {
+			if walkError != nil {
+				return walkError
+			}
+			if info.IsDir() && file != path && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() && containsString(LINK_FILE_EXTENSIONS, strings.ToLower(filepath.Ext(file))) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if walkError != nil {
+			return nil, walkError
+		}
+	}
+	return files, nil
+}
+
+// Returns the links of a Markdown, HTML or reStructuredText file with their line numbers,
+// chosen by the file extension. HTML attributes are also found in Markdown files.
+func ExtractFileLinks(path string) ([]FileLink, error) {
+	file, openError := os.Open(path)
+	if openError != nil {
+		return nil, openError
+	}
+	defer file.Close()
+
+	patterns := []*regexp.Regexp{HTML_LINK_ATTRIBUTE}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		patterns = append(patterns, MARKDOWN_INLINE_LINK, MARKDOWN_REFERENCE_LINK, AUTOLINK)
+	case ".rst":
+		patterns = []*regexp.Regexp{RST_INLINE_LINK, RST_TARGET}
+	}
+
+	var links []FileLink
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		for _, pattern := range patterns {
+			for _, match := range pattern.FindAllStringSubmatch(scanner.Text(), -1) {
+				links = append(links, FileLink{File: path, Line: line, Target: strings.TrimSpace(match[1])})
+			}
+		}
+	}
+	return links, scanner.Err()
+}
+
+// Checks the links found in local files, requesting remote URLs over HTTP unless offline and checking that
+// relative links point to existing files. Every remote URL is requested once, however often it is linked.
+// Links to fragments of the same file and other schemes such as mailto are skipped.
+func CheckFileLinks(ctx context.Context, links []FileLink, options Options, fileOptions FileCheckOptions) <-chan FileLinkResult {
+	options = options.withDefaults()
+	client := &http.Client{
+		Transport: newRetryTransport(&timeoutTransport{transport: getTransport(newCertificateTracker(), options), timeout: options.Timeout}, options.Retries, options.RetryDelay, options.Debug),
+	}
+	remote := &remoteLinkCache{results: make(map[string]*remoteLinkResult)}
+
+	results := make(chan FileLinkResult, RESULTS_BUFFER_SIZE)
+	pending := make(chan FileLink)
+	var workers sync.WaitGroup
+	for worker := 0; worker < options.Threads; worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for link := range pending {
+				if result, checked := checkFileLink(ctx, client, remote, options, fileOptions, link); checked {
+					results <- result
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for _, link := range links {
+			if ctx.Err() != nil {
+				break
+			}
+			pending <- link
+		}
+		close(pending)
+		workers.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func checkFileLink(ctx context.Context, client *http.Client, remote *remoteLinkCache, options Options, fileOptions FileCheckOptions, link FileLink) (FileLinkResult, bool) {
+	result := FileLinkResult{FileLink: link}
+	target, parseError := url.Parse(link.Target)
+	if parseError != nil {
+		result.Err = fmt.Errorf("Invalid link: %s", parseError)
+		return result, true
+	}
+
+	switch {
+	case target.Scheme == "http" || target.Scheme == "https":
+		if fileOptions.Offline {
+			return result, false
+		}
+		result.Status, result.Err = remote.check(link.Target, func() (int, error) {
+			return requestFileLink(ctx, client, options, link.Target)
+		})
+		return result, true
+	case target.Scheme != "" || target.Host != "" || target.Path == "":
+		return result, false
+	}
+
+	path, unescapeError := url.PathUnescape(target.Path)
+	if unescapeError != nil {
+		path = target.Path
+	}
+	if strings.HasPrefix(path, "/") {
+		path = filepath.Join(fileOptions.Root, filepath.FromSlash(path))
+	} else {
+		path = filepath.Join(filepath.Dir(link.File), filepath.FromSlash(path))
+	}
+	if _, statError := os.Stat(path); statError != nil {
+		result.Err = fmt.Errorf("File not found: %s", path)
+	}
+	return result, true
+}
+
+func requestFileLink(ctx context.Context, client *http.Client, options Options, link string) (int, error) {
+	request, requestError := http.NewRequest("GET", link, nil)
+	if requestError != nil {
+		return 0, requestError
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", options.UserAgent)
+	applyHeaderRules(options.HeaderRules, request.URL.Hostname(), request.Header)
+
+	response, responseError := client.Do(request)
+	if responseError != nil {
+		return 0, responseError
+	}
+	response.Body.Close()
+
+	if !options.HealthyCodes.Contains(response.StatusCode) && !options.WarningCodes.Contains(response.StatusCode) {
+		return response.StatusCode, fmt.Errorf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	return response.StatusCode, nil
+}
+
+// Remembers the outcome of every remote URL, so URLs linked from several files are requested once
+type remoteLinkCache struct {
+	lock    sync.Mutex
+	results map[string]*remoteLinkResult
+}
+
+type remoteLinkResult struct {
+	once   sync.Once
+	status int
+	err    error
+}
+
+func (cache *remoteLinkCache) check(link string, request func() (int, error)) (int, error) {
+	cache.lock.Lock()
+	result, ok := cache.results[link]
+	if !ok {
+		result = &remoteLinkResult{}
+		cache.results[link] = result
+	}
+	cache.lock.Unlock()
+
+	result.once.Do(func() {
+		result.status, result.err = request()
+	})
+	return result.status, result.err
+}
@@ -0,0 +1,139 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// A link to a fragment of a page, found on the parent page
+type fragmentReference struct {
+	page     string
+	fragment string
+	parent   string
+}
+
+// Collects the fragments links point to and the anchors of every parsed page, so links to missing
+// anchors can be reported once the crawl finishes. Anchors are elements with an id, or a elements with a name.
+type fragmentTracker struct {
+	lock       sync.Mutex
+	anchors    map[string]map[string]bool
+	statuses   map[string]int
+	references []fragmentReference
+}
+
+func newFragmentTracker() *fragmentTracker {
+	return &fragmentTracker{
+		anchors:  make(map[string]map[string]bool),
+		statuses: make(map[string]int),
+	}
+}
+
+// Records the fragment the link found on the page points to, if any
+func (tracker *fragmentTracker) referenced(element *colly.HTMLElement, link string) {
+	parsedLink, parseError := url.Parse(link)
+	if parseError != nil || !isCheckableFragment(parsedLink.Fragment) {
+		return
+	}
+
+	page := element.Request.URL.String()
+	if !strings.HasPrefix(link, "#") {
+		page = element.Request.AbsoluteURL(link)
+	}
+	if page == "" {
+		return
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.references = append(tracker.references, fragmentReference{
+		page:     page,
+		fragment: parsedLink.Fragment,
+		parent:   element.Request.URL.String(),
+	})
+}
+
+// Records the anchors of a parsed page under each URL it is known by
+func (tracker *fragmentTracker) found(element *colly.HTMLElement, pages ...string) {
+	anchors := make(map[string]bool)
+	element.DOM.Find("[id], a[name]").Each(func(_ int, selection *goquery.Selection) {
+		if id, ok := selection.Attr("id"); ok {
+			anchors[id] = true
+		}
+		if name, ok := selection.Attr("name"); ok && goquery.NodeName(selection) == "a" {
+			anchors[name] = true
+		}
+	})
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	for _, page := range pages {
+		tracker.anchors[page] = anchors
+		tracker.statuses[page] = element.Response.StatusCode
+	}
+}
+
+// Returns a broken result for every fragment missing from its parsed page, with every page linking to it.
+// Fragments of pages that were not parsed, e.g. because they were not visited, are not reported.
+func (tracker *fragmentTracker) missing() []Result {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	parents := make(map[string][]string)
+	var links []string
+	for _, reference := range tracker.references {
+		anchors, parsed := tracker.anchors[reference.page]
+		if !parsed || anchors[reference.fragment] {
+			continue
+		}
+
+		link := reference.page + "#" + url.PathEscape(reference.fragment)
+		if _, seen := parents[link]; !seen {
+			links = append(links, link)
+		}
+		if !containsString(parents[link], reference.parent) {
+			parents[link] = append(parents[link], reference.parent)
+		}
+	}
+	sort.Strings(links)
+
+	var results []Result
+	for _, link := range links {
+		linkURL, _ := url.Parse(link)
+		page := strings.SplitN(link, "#", 2)[0]
+		results = append(results, Result{
+			Link: Link{
+				URL:       linkURL,
+				Status:    tracker.statuses[page],
+				Parents:   parents[link],
+				CheckedAt: time.Now(),
+			},
+			Err: withCategory(ERROR_CATEGORY_INVALID_LINK, fmt.Errorf("Missing anchor #%s", linkURL.Fragment)),
+		})
+	}
+	return results
+}
+
+// Fragments referring to the top of the page and client side routes such as #/path or #!/path
+// do not need a matching anchor
+func isCheckableFragment(fragment string) bool {
+	if fragment == "" || strings.EqualFold(fragment, "top") {
+		return false
+	}
+	return !strings.HasPrefix(fragment, "/") && !strings.HasPrefix(fragment, "!")
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package linkhealth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Internal header marking GET requests that may be made as HEAD requests, removed before the request is sent
+const HEAD_FIRST_HEADER = "X-Simple-Link-Health-Head-First"
+
+// Context key marking the same requests, since the response of a HEAD request has no body to check
+const HEAD_FIRST_CONTEXT_KEY = "headFirst"
+
+// Makes marked GET requests as HEAD requests first, saving the transfer of bodies that are never parsed.
+// Falls back to GET when the server does not support HEAD or reports an error, since some servers
+// answer HEAD requests differently than GET requests.
+type headFirstTransport struct {
+	transport http.RoundTripper
+}
+
+func (headFirst *headFirstTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header.Get(HEAD_FIRST_HEADER) == "" {
+		return headFirst.transport.RoundTrip(request)
+	}
+
+	getRequest := request.Clone(request.Context())
+	getRequest.Header.Del(HEAD_FIRST_HEADER)
+	if request.Method != "GET" {
+		return headFirst.transport.RoundTrip(getRequest)
+	}
+
+	headRequest := getRequest.Clone(request.Context())
+	headRequest.Method = "HEAD"
+	response, headError := headFirst.transport.RoundTrip(headRequest)
+	if headError == nil && !shouldFallBackToGet(response.StatusCode) {
+		// Report the response as the response to the original request so redirects are followed with GET
+		response.Request = getRequest
+		return response, nil
+	}
+
+	if headError == nil {
+		_, _ = ioutil.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	return headFirst.transport.RoundTrip(getRequest)
+}
+
+// Checks whether a HEAD response is implausible. This covers servers not supporting HEAD (405, 501)
+// as well as links that seem broken, which are confirmed with a GET request.
+func shouldFallBackToGet(status int) bool {
+	return status >= http.StatusBadRequest
+}
+
+// A set of URLs safe for concurrent use
+type linkSet struct {
+	lock  sync.RWMutex
+	links map[string]bool
+}
+
+func newLinkSet() *linkSet {
+	return &linkSet{links: make(map[string]bool)}
+}
+
+func (set *linkSet) add(link string) {
+	set.lock.Lock()
+	set.links[link] = true
+	set.lock.Unlock()
+}
+
+func (set *linkSet) contains(link string) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+	return set.links[link]
+}
@@ -0,0 +1,73 @@
+package linkhealth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+)
+
+// A set of headers applied to every request whose host matches the host pattern.
+// Host patterns use path.Match syntax, e.g. "*.example.com".
+type HeaderRule struct {
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Loads the header rules from a JSON file containing a list of rules
+func LoadHeaderRules(rulesPath string) ([]HeaderRule, error) {
+	content, readError := ioutil.ReadFile(rulesPath)
+	if readError != nil {
+		return nil, readError
+	}
+
+	var rules []HeaderRule
+	if jsonError := json.Unmarshal(content, &rules); jsonError != nil {
+		return nil, fmt.Errorf("Invalid header rules file %s: %s", rulesPath, jsonError)
+	}
+
+	for _, rule := range rules {
+		if patternError := ValidateHostPattern(rule.Host); patternError != nil {
+			return nil, fmt.Errorf("%s in header rules file %s", patternError, rulesPath)
+		}
+	}
+
+	return rules, nil
+}
+
+// Checks that a host pattern of a header rule is a valid path.Match pattern
+func ValidateHostPattern(pattern string) error {
+	if _, matchError := path.Match(pattern, ""); matchError != nil || pattern == "" {
+		return fmt.Errorf("Invalid host pattern %q", pattern)
+	}
+	return nil
+}
+
+// Checks whether the rule applies to the host
+func (rule *HeaderRule) matches(host string) bool {
+	matched, _ := path.Match(rule.Host, host)
+	return matched
+}
+
+// Sets the headers of every rule matching the host, later rules take precedence over earlier ones
+func applyHeaderRules(rules []HeaderRule, host string, headers http.Header) {
+	for _, rule := range rules {
+		if rule.matches(host) {
+			for name, value := range rule.Headers {
+				headers.Set(name, value)
+			}
+		}
+	}
+}
+
+// Removes the headers of every rule not matching the host, used when a redirect copies headers to a new host
+func removeHeaderRules(rules []HeaderRule, host string, headers http.Header) {
+	for _, rule := range rules {
+		if !rule.matches(host) {
+			for name := range rule.Headers {
+				headers.Del(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package linkhealth
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Custom checks of checked links, for domain specific problems such as internal links missing a required
+// query parameter. See Options.LinkValidators
+type LinkValidator interface {
+	// Returns why the link is broken despite its healthy status, or nil. Called for every link with a
+	// healthy status, possibly from several goroutines at once
+	Validate(response ValidatedResponse) error
+}
+
+// A function used as a LinkValidator
+type LinkValidatorFunc func(response ValidatedResponse) error
+
+func (validate LinkValidatorFunc) Validate(response ValidatedResponse) error {
+	return validate(response)
+}
+
+// The response of a checked link passed to the LinkValidators. The body is empty for responses to HEAD requests
+type ValidatedResponse struct {
+	Link    Link
+	Headers http.Header
+	Body    []byte
+}
+
+// Hook points letting library users add checks and result sinks to a crawl. Hooks may be called from
+// several goroutines at once.
+type Hooks struct {
+	// Called for every link found on a page before it is queued, the link is skipped when it returns false
+	OnLinkDiscovered func(link *url.URL, page *url.URL) bool
+	// Called with every result before it is sent on the results channel
+	OnResult func(result Result)
+}
+
+// Runs the validators of the options in order, returning the first failure as a custom error
+func validateLink(validators []LinkValidator, response ValidatedResponse) error {
+	for _, validator := range validators {
+		if validationError := validator.Validate(response); validationError != nil {
+			return withCategory(ERROR_CATEGORY_CUSTOM, validationError)
+		}
+	}
+	return nil
+}
+
+// Checks whether the link failed a check despite its healthy status, see checkContent and validateLink
+func isSoftError(err error) bool {
+	if err == nil {
+		return false
+	}
+	category := CategorizeError(err, 0)
+	return category == ERROR_CATEGORY_CONTENT || category == ERROR_CATEGORY_CUSTOM
+}
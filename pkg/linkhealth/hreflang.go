@@ -0,0 +1,116 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// Selector of the alternate language versions declared by a page
+const HREFLANG_SELECTOR = "link[rel=alternate][hreflang][href]"
+
+// Language codes with an optional region or script such as en, en-GB or zh-Hant, and x-default
+var HREFLANG_PATTERN = regexp.MustCompile(`(?i)^([a-z]{2,3}(-[a-z0-9]{2,8})*|x-default)$`)
+
+// The alternate language versions declared by a parsed page
+type hreflangPage struct {
+	// The URLs the page is known by, the URL it was found as and the URL it redirected to
+	urls []string
+	// The URL of each alternate by its language code
+	alternates map[string]string
+}
+
+// Collects the hreflang alternates of parsed pages, so alternates not linking back to the pages declaring
+// them can be reported once the crawl finishes
+type hreflangTracker struct {
+	lock sync.Mutex
+	// Parsed pages declaring alternates, by the normalized form of each URL they are known by
+	pages map[string]*hreflangPage
+	// Page URLs in the order they were parsed, each page once
+	order []string
+}
+
+func newHreflangTracker() *hreflangTracker {
+	return &hreflangTracker{pages: make(map[string]*hreflangPage)}
+}
+
+// Records the alternates of a parsed page under each URL it is known by, returning the hrefs of the
+// alternates with valid language codes and a warning for each invalid code
+func (tracker *hreflangTracker) found(element *colly.HTMLElement, pages ...string) ([]string, []string) {
+	page := &hreflangPage{urls: pages, alternates: make(map[string]string)}
+	var hrefs, warnings []string
+	element.DOM.Find(HREFLANG_SELECTOR).Each(func(_ int, selection *goquery.Selection) {
+		language, _ := selection.Attr("hreflang")
+		href, _ := selection.Attr("href")
+		if !HREFLANG_PATTERN.MatchString(language) {
+			warnings = append(warnings, fmt.Sprintf("Invalid hreflang %q on %s", language, element.Request.URL))
+			return
+		}
+		if alternate := element.Request.AbsoluteURL(cleanHref(href)); alternate != "" {
+			page.alternates[language] = alternate
+			hrefs = append(hrefs, href)
+		}
+	})
+	if len(page.alternates) == 0 {
+		return hrefs, warnings
+	}
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.order = append(tracker.order, pages[0])
+	for _, pageURL := range pages {
+		tracker.pages[NormalizeURL(pageURL, false)] = page
+	}
+	return hrefs, warnings
+}
+
+// Returns a warning for every alternate that was parsed but does not declare the page back as one of its
+// alternates. Alternates that were not parsed, e.g. because they are broken or beyond the max depth, are
+// not reported here.
+func (tracker *hreflangTracker) warnings() []Result {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	order := append([]string(nil), tracker.order...)
+	sort.Strings(order)
+	var results []Result
+	for _, pageURL := range order {
+		page := tracker.pages[NormalizeURL(pageURL, false)]
+		languages := make([]string, 0, len(page.alternates))
+		for language := range page.alternates {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+
+		for _, language := range languages {
+			alternate := page.alternates[language]
+			alternatePage, parsed := tracker.pages[NormalizeURL(alternate, false)]
+			if !parsed || alternatePage == page || linksBack(alternatePage, page) {
+				continue
+			}
+			linkURL, _ := url.Parse(pageURL)
+			results = append(results, Result{
+				Link:    Link{URL: linkURL},
+				Warning: fmt.Sprintf("%s declares %s as its %s alternate, but %s does not declare it back with hreflang", pageURL, alternate, language, alternate),
+			})
+		}
+	}
+	return results
+}
+
+// Checks whether any alternate of the page is one of the URLs of the other page
+func linksBack(page *hreflangPage, other *hreflangPage) bool {
+	for _, alternate := range page.alternates {
+		for _, otherURL := range other.urls {
+			if NormalizeURL(alternate, false) == NormalizeURL(otherURL, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
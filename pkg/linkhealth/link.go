@@ -0,0 +1,73 @@
+package linkhealth
+
+import (
+	"net/url"
+	"time"
+)
+
+const (
+	DEFAULT_HEALTHY_HTTP_MIN_STATUS_CODE = 200
+	DEFAULT_HEALTHY_HTTP_MAX_STATUS_CODE = 299
+)
+
+// Represents a requested link containing the url and status derived from the requests response.
+type Link struct {
+	Status int
+	URL    *url.URL
+	// Pages the link was found on by the time it was checked, empty for starting URLs
+	Parents []string
+	// Depth the link was found at, 1 for starting URLs, see Options.Depth
+	Depth int
+	// Pages the crawl took from a starting URL to the link, ending with the link
+	CrawlPath []string
+	// Hops followed before reaching the URL, empty when the link did not redirect
+	Redirects []Redirect
+	// Time from sending the request until the response body was read
+	Latency time.Duration
+	// Time from sending the request until the response headers arrived
+	TimeToFirstByte time.Duration
+	CheckedAt       time.Time
+	// Days until the server certificate expires, only set when HasCertificate is true
+	CertExpiryDays int
+	HasCertificate bool
+	// Whether the status is one of the healthy or warning codes of the crawl, see Options.HealthyCodes
+	Healthy bool
+	// Closest archived snapshot of a dead link, only looked up with Options.Wayback
+	ArchivedURL string
+	// Bytes of the response body, only set when HasSize is true as bodies of HEAD requests and 304 responses
+	// are not downloaded
+	Size    int
+	HasSize bool
+	// Whether a healthy link took longer than Options.SlowThreshold to respond, it is degraded but still healthy
+	Slow bool
+}
+
+// Checks whether the link was healthy by using the link status
+func (link *Link) IsHealthy() bool {
+	return link.Healthy
+}
+
+// Represents an outcome reported while crawling. A result is either the response of a checked link,
+// a failed request when Err is set, or a warning about the link when Warning is set.
+type Result struct {
+	Link
+	Err     error
+	Warning string
+	// One of the WARNING_KIND constants, empty for other warnings
+	WarningKind string
+	// One of the SEVERITY constants, empty for healthy links, see Options.Severities
+	Severity string
+}
+
+// Checks whether the result is a warning rather than the outcome of a request
+func (result *Result) IsWarning() bool {
+	return result.Warning != ""
+}
+
+// Returns the category of the failure, one of the ERROR_CATEGORY constants, empty unless the result is a broken link
+func (result *Result) Category() string {
+	if result.IsWarning() || (result.Err == nil && result.IsHealthy()) {
+		return ""
+	}
+	return CategorizeError(result.Err, result.Status)
+}
@@ -0,0 +1,123 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+)
+
+// Login pages larger than this are only parsed up to this size
+const MAX_LOGIN_PAGE_SIZE = 10 * 1024 * 1024
+
+// Login form submitted before crawling, see Options.Login
+type LoginForm struct {
+	// Page containing the login form
+	URL string
+	// Form fields to submit, e.g. the user name and password
+	Fields url.Values
+}
+
+// Submits the login form, storing the resulting session cookies in the jar. When the login page contains
+// a form with a password field, its action, method and hidden fields such as CSRF tokens are used,
+// otherwise the fields are posted to the login page itself.
+func login(ctx context.Context, form *LoginForm, options Options, jar http.CookieJar) error {
+	client := &http.Client{
+		Transport: getTransport(newCertificateTracker(), options),
+		Jar:       jar,
+		Timeout:   options.Timeout,
+	}
+
+	loginURL, urlError := url.Parse(form.URL)
+	if urlError != nil {
+		return fmt.Errorf("Invalid login URL %q: %s", form.URL, urlError)
+	}
+
+	page, pageError := sendLoginRequest(ctx, client, options, "GET", loginURL, nil)
+	if pageError != nil {
+		return pageError
+	}
+	defer page.Body.Close()
+
+	action, method, fields := loginURL, "POST", url.Values{}
+	if document, parseError := goquery.NewDocumentFromReader(io.LimitReader(page.Body, MAX_LOGIN_PAGE_SIZE)); parseError == nil {
+		if selection := document.Find("form:has(input[type=password])").First(); selection.Length() > 0 {
+			if formAction, ok := selection.Attr("action"); ok && strings.TrimSpace(formAction) != "" {
+				if resolved, resolveError := page.Request.URL.Parse(strings.TrimSpace(formAction)); resolveError == nil {
+					action = resolved
+				}
+			} else {
+				action = page.Request.URL
+			}
+			if formMethod, ok := selection.Attr("method"); ok && strings.EqualFold(formMethod, "GET") {
+				method = "GET"
+			}
+			selection.Find("input[type=hidden][name]").Each(func(_ int, input *goquery.Selection) {
+				name, _ := input.Attr("name")
+				value, _ := input.Attr("value")
+				fields.Set(name, value)
+			})
+		}
+	}
+	for name, values := range form.Fields {
+		fields[name] = values
+	}
+
+	response, loginError := sendLoginRequest(ctx, client, options, method, action, fields)
+	if loginError != nil {
+		return loginError
+	}
+	_, _ = ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	return nil
+}
+
+func sendLoginRequest(ctx context.Context, client *http.Client, options Options, method string, target *url.URL, fields url.Values) (*http.Response, error) {
+	var body io.Reader
+	requestURL := *target
+	if method == "GET" && fields != nil {
+		requestURL.RawQuery = fields.Encode()
+	} else if fields != nil {
+		body = strings.NewReader(fields.Encode())
+	}
+
+	request, requestError := http.NewRequest(method, requestURL.String(), body)
+	if requestError != nil {
+		return nil, requestError
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", options.UserAgent)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	applyHeaderRules(options.HeaderRules, request.URL.Hostname(), request.Header)
+
+	response, responseError := client.Do(request)
+	if responseError != nil {
+		return nil, fmt.Errorf("Login failed: %s", responseError)
+	}
+	if !options.HealthyCodes.Contains(response.StatusCode) {
+		response.Body.Close()
+		return nil, fmt.Errorf("Login failed: %s responded with %d %s", target, response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	return response, nil
+}
+
+// Exposes the cookies of a collector as a cookie jar, so session cookies set while logging in are used by the crawl
+type collectorJar struct {
+	collector *colly.Collector
+}
+
+func (jar *collectorJar) SetCookies(link *url.URL, cookies []*http.Cookie) {
+	_ = jar.collector.SetCookies(link.String(), cookies)
+}
+
+func (jar *collectorJar) Cookies(link *url.URL) []*http.Cookie {
+	return jar.collector.Cookies(link.String())
+}
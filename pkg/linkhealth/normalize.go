@@ -0,0 +1,95 @@
+package linkhealth
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Ports implied by the scheme, removed when normalizing URLs
+var DEFAULT_PORTS = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Returns the canonical form of a URL, used to recognize links to the same page. The scheme and host are
+// lowercased, default ports, fragments and trailing slashes are removed and query parameters are sorted.
+// An empty path is the root path, as http://example.com and http://example.com/ are the same page.
+// The query is removed entirely when stripQuery is set.
+func NormalizeURL(link string, stripQuery bool) string {
+	parsed, parseError := url.Parse(link)
+	if parseError != nil {
+		return link
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); port != "" && DEFAULT_PORTS[parsed.Scheme] == port {
+		parsed.Host = strings.TrimSuffix(parsed.Host, ":"+port)
+	}
+	parsed.Fragment = ""
+	if parsed.Path == "" && parsed.Opaque == "" && parsed.Host != "" {
+		parsed.Path = "/"
+	}
+	if parsed.Path != "/" {
+		parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+		parsed.RawPath = strings.TrimSuffix(parsed.RawPath, "/")
+	}
+	if stripQuery {
+		parsed.RawQuery = ""
+		parsed.ForceQuery = false
+	} else if parsed.RawQuery != "" {
+		if query, queryError := url.ParseQuery(parsed.RawQuery); queryError == nil {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	return parsed.String()
+}
+
+// Removes the query of a URL, keeping the URL as is when it cannot be parsed
+func stripQuery(link string) string {
+	parsed, parseError := url.Parse(link)
+	if parseError != nil {
+		return link
+	}
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	return parsed.String()
+}
+
+// Tracks the URLs visited by their canonical form, so each page is only checked once however its links are written
+type visitedTracker struct {
+	lock       sync.Mutex
+	stripQuery bool
+	// First visited URL per canonical URL
+	visited map[string]string
+}
+
+func newVisitedTracker(stripQuery bool) *visitedTracker {
+	return &visitedTracker{stripQuery: stripQuery, visited: make(map[string]string)}
+}
+
+// Claims the canonical form of the URL for it, returning the URL that claimed it first
+// and whether the canonical form was claimed by this call
+func (tracker *visitedTracker) claim(link string) (string, bool) {
+	key := NormalizeURL(link, tracker.stripQuery)
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if firstVisited, ok := tracker.visited[key]; ok {
+		return firstVisited, false
+	}
+	tracker.visited[key] = link
+	return link, true
+}
+
+// Releases a claimed URL that could not be visited, so it can be visited when linked again
+func (tracker *visitedTracker) release(link string) {
+	key := NormalizeURL(link, tracker.stripQuery)
+
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if tracker.visited[key] == link {
+		delete(tracker.visited, key)
+	}
+}
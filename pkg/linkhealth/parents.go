@@ -0,0 +1,138 @@
+package linkhealth
+
+import (
+	"sync"
+
+	"github.com/gocolly/colly"
+)
+
+// Tracks every page each link was found on. Requests are associated with the URL they were made for,
+// since the request URL changes when a link redirects.
+type parentTracker struct {
+	lock     sync.Mutex
+	parents  map[string][]string
+	requests map[uint32]string
+	// Maps the final URL of a redirected link to the URL it was found as
+	redirects map[string]string
+	// The page each followed link was queued from, empty for starting URLs
+	via map[string]string
+}
+
+func newParentTracker() *parentTracker {
+	return &parentTracker{
+		parents:   make(map[string][]string),
+		requests:  make(map[uint32]string),
+		redirects: make(map[string]string),
+		via:       make(map[string]string),
+	}
+}
+
+// Records the page the link was found on
+func (tracker *parentTracker) discovered(link string, page string) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	for _, parent := range tracker.parents[link] {
+		if parent == page {
+			return
+		}
+	}
+	tracker.parents[link] = append(tracker.parents[link], page)
+}
+
+// Records the page a link was queued from, the first page it was found on that led the crawl to it
+func (tracker *parentTracker) queued(link string, page string) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.via[link] = page
+}
+
+// Associates the request with the URL it was made for, called before the request is made
+func (tracker *parentTracker) requested(request *colly.Request) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.requests[request.ID] = request.URL.String()
+}
+
+// Returns the pages the requested link has been found on so far, empty for starting URLs
+func (tracker *parentTracker) parentsOf(request *colly.Request) []string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	link, ok := tracker.requests[request.ID]
+	if !ok {
+		link = request.URL.String()
+	}
+	delete(tracker.requests, request.ID)
+
+	if final := request.URL.String(); final != link {
+		tracker.redirects[final] = link
+	}
+
+	return append([]string(nil), tracker.parents[link]...)
+}
+
+// Returns every page the link has been found on so far
+func (tracker *parentTracker) linkedFrom(link string) []string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if original, ok := tracker.redirects[link]; ok {
+		link = original
+	}
+	return append([]string(nil), tracker.parents[link]...)
+}
+
+// Returns the URL a redirected link was found as, or the link itself
+func (tracker *parentTracker) original(link string) string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if original, ok := tracker.redirects[link]; ok {
+		return original
+	}
+	return link
+}
+
+// Returns the pages leading from a starting URL to the link, each queued from the previous one, ending with
+// the link itself
+func (tracker *parentTracker) crawlPath(link string) []string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	path := []string{link}
+	seen := map[string]bool{link: true}
+	for {
+		if original, ok := tracker.redirects[link]; ok {
+			link = original
+		}
+		page, queued := tracker.via[link]
+		if !queued || seen[page] {
+			break
+		}
+		link = page
+		seen[link] = true
+		path = append(path, link)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Returns a copy of the pages every link has been found on
+func (tracker *parentTracker) snapshot() map[string][]string {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	parents := make(map[string][]string, len(tracker.parents))
+	for link, pages := range tracker.parents {
+		parents[link] = append([]string(nil), pages...)
+	}
+	return parents
+}
+
+// Restores the pages links were found on, before crawling
+func (tracker *parentTracker) restore(parents map[string][]string) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	for link, pages := range parents {
+		tracker.parents[link] = pages
+	}
+}
@@ -0,0 +1,88 @@
+package linkhealth
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Counters of a crawl in progress, see Checker.Progress
+type Progress struct {
+	// Links waiting in the queue to be requested
+	QueueDepth int
+	// Requests made or waiting for a rate limit or Crawl-delay
+	Requested int
+	// Requests that got a response or failed, or were skipped before being sent
+	Finished int
+	// Links checked so far, and how many of them are broken
+	Checked int
+	Broken  int
+	Started time.Time
+}
+
+// Returns the number of links waiting in the queue or being requested
+func (progress Progress) Queued() int {
+	if progress.Requested < progress.Finished {
+		return progress.QueueDepth
+	}
+	return progress.QueueDepth + progress.Requested - progress.Finished
+}
+
+// Returns the average number of requests finished per second since the crawl started
+func (progress Progress) RequestsPerSecond() float64 {
+	elapsed := time.Since(progress.Started).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(progress.Finished) / elapsed
+}
+
+// Counts the requests and results of a crawl, safe to read while crawling
+type progressCounters struct {
+	queued    int64
+	requested int64
+	finished  int64
+	checked   int64
+	broken    int64
+	started   atomic.Value
+}
+
+func newProgressCounters() *progressCounters {
+	counters := &progressCounters{}
+	counters.started.Store(time.Now())
+	return counters
+}
+
+// Restarts the elapsed time, once the crawl starts
+func (counters *progressCounters) start() {
+	counters.started.Store(time.Now())
+}
+
+func (counters *progressCounters) queue(length int) {
+	atomic.StoreInt64(&counters.queued, int64(length))
+}
+
+func (counters *progressCounters) request() {
+	atomic.AddInt64(&counters.requested, 1)
+}
+
+func (counters *progressCounters) finish() {
+	atomic.AddInt64(&counters.finished, 1)
+}
+
+func (counters *progressCounters) check(broken bool) {
+	atomic.AddInt64(&counters.checked, 1)
+	if broken {
+		atomic.AddInt64(&counters.broken, 1)
+	}
+}
+
+func (counters *progressCounters) snapshot() Progress {
+	return Progress{
+		QueueDepth: int(atomic.LoadInt64(&counters.queued)),
+		Requested:  int(atomic.LoadInt64(&counters.requested)),
+		Finished:   int(atomic.LoadInt64(&counters.finished)),
+		Checked:    int(atomic.LoadInt64(&counters.checked)),
+		Broken:     int(atomic.LoadInt64(&counters.broken)),
+		Started:    counters.started.Load().(time.Time),
+	}
+}
@@ -0,0 +1,103 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Query parameters added for tracking or to carry session IDs, which make the same page show up under many URLs
+var TRACKING_PARAMS = []string{"utm_*", "gclid", "dclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga", "_hsenc", "_hsmi", "sessionid", "jsessionid", "phpsessid"}
+
+// Rewrites discovered links before they are deduplicated and visited, removing the query and path parameters
+// matching its patterns and sorting the remaining query parameters
+type queryRewriter struct {
+	// Lowercase parameter names, in path.Match syntax
+	patterns  []string
+	sortQuery bool
+}
+
+// Returns a rewriter removing the parameters matching the patterns, e.g. utm_*, compared case-insensitively.
+// Returns nil when links are left as they are.
+func newQueryRewriter(patterns []string, sortQuery bool) (*queryRewriter, error) {
+	if len(patterns) == 0 && !sortQuery {
+		return nil, nil
+	}
+
+	rewriter := &queryRewriter{sortQuery: sortQuery}
+	for _, pattern := range patterns {
+		if _, matchError := path.Match(pattern, ""); matchError != nil {
+			return nil, fmt.Errorf("Invalid parameter pattern %q: %s", pattern, matchError)
+		}
+		rewriter.patterns = append(rewriter.patterns, strings.ToLower(pattern))
+	}
+	return rewriter, nil
+}
+
+// Rewrites an absolute link, keeping it as is when it cannot be parsed
+func (rewriter *queryRewriter) rewrite(link string) string {
+	parsed, parseError := url.Parse(link)
+	if parseError != nil {
+		return link
+	}
+
+	// Path parameters such as ;jsessionid=... are removed from every segment
+	if escapedPath := parsed.EscapedPath(); strings.Contains(escapedPath, ";") {
+		segments := strings.Split(escapedPath, "/")
+		for index, segment := range segments {
+			parameters := strings.Split(segment, ";")
+			segments[index] = strings.Join(append(parameters[:1], rewriter.keep(parameters[1:])...), ";")
+		}
+		if rewritten := strings.Join(segments, "/"); rewritten != escapedPath {
+			if unescaped, unescapeError := url.PathUnescape(rewritten); unescapeError == nil {
+				parsed.Path = unescaped
+				parsed.RawPath = rewritten
+			}
+		}
+	}
+
+	if parsed.RawQuery != "" {
+		parameters := rewriter.keep(strings.Split(parsed.RawQuery, "&"))
+		if rewriter.sortQuery {
+			sort.SliceStable(parameters, func(i, j int) bool {
+				return getParameterName(parameters[i]) < getParameterName(parameters[j])
+			})
+		}
+		parsed.RawQuery = strings.Join(parameters, "&")
+		parsed.ForceQuery = false
+	}
+	return parsed.String()
+}
+
+// Returns the name=value parameters whose name does not match any pattern, in order
+func (rewriter *queryRewriter) keep(parameters []string) []string {
+	var kept []string
+	for _, parameter := range parameters {
+		if parameter == "" {
+			continue
+		}
+		name := strings.ToLower(getParameterName(parameter))
+		matched := false
+		for _, pattern := range rewriter.patterns {
+			if matches, _ := path.Match(pattern, name); matches {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, parameter)
+		}
+	}
+	return kept
+}
+
+// Returns the unescaped name of a name=value parameter
+func getParameterName(parameter string) string {
+	name := strings.SplitN(parameter, "=", 2)[0]
+	if unescaped, unescapeError := url.QueryUnescape(name); unescapeError == nil {
+		return unescaped
+	}
+	return name
+}
@@ -0,0 +1,53 @@
+package linkhealth
+
+import "testing"
+
+func TestQueryRewriterRewrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		patterns  []string
+		sortQuery bool
+		link      string
+		want      string
+	}{
+		{"no query", TRACKING_PARAMS, false, "https://site.com/page", "https://site.com/page"},
+		{"tracking parameters", TRACKING_PARAMS, false, "https://site.com/page?utm_source=news&id=3&utm_medium=email&gclid=abc", "https://site.com/page?id=3"},
+		{"names compared case-insensitively", []string{"utm_*"}, false, "https://site.com/?UTM_Source=news&a=1", "https://site.com/?a=1"},
+		{"escaped names", []string{"utm_source"}, false, "https://site.com/?utm%5Fsource=news&a=1", "https://site.com/?a=1"},
+		{"only removed parameters", TRACKING_PARAMS, false, "https://site.com/page?utm_source=news&fbclid=1", "https://site.com/page"},
+		{"empty parameters dropped", []string{"ref"}, false, "https://site.com/?a=1&&ref=x&", "https://site.com/?a=1"},
+		{"parameters without values", []string{"debug"}, false, "https://site.com/?debug&a", "https://site.com/?a"},
+		{"values kept as they are", []string{"ref"}, false, "https://site.com/?q=a%20b&ref=x&next=%2Fhome", "https://site.com/?q=a%20b&next=%2Fhome"},
+		{"fragment kept", TRACKING_PARAMS, false, "https://site.com/page?utm_source=x&a=1#section", "https://site.com/page?a=1#section"},
+		{"sorted", nil, true, "https://site.com/?c=3&a=1&b=2", "https://site.com/?a=1&b=2&c=3"},
+		{"sorted by name keeping repeated order", nil, true, "https://site.com/?b=2&a=z&a=y", "https://site.com/?a=z&a=y&b=2"},
+		{"removed then sorted", TRACKING_PARAMS, true, "https://site.com/?utm_campaign=x&z=1&b=2", "https://site.com/?b=2&z=1"},
+		{"jsessionid path parameter", TRACKING_PARAMS, false, "https://site.com/shop/cart;jsessionid=0123ABCD?item=4", "https://site.com/shop/cart?item=4"},
+		{"uppercase path parameter", TRACKING_PARAMS, false, "https://site.com/cart;JSESSIONID=0123", "https://site.com/cart"},
+		{"path parameters of every segment", TRACKING_PARAMS, false, "https://site.com/a;jsessionid=1/b;phpsessid=2/c", "https://site.com/a/b/c"},
+		{"other path parameters kept", TRACKING_PARAMS, false, "https://site.com/page;v=2;jsessionid=1", "https://site.com/page;v=2"},
+		{"escaped path kept", TRACKING_PARAMS, false, "https://site.com/a%20b;jsessionid=1/c%2Fd", "https://site.com/a%20b/c%2Fd"},
+		{"path parameters without patterns", nil, true, "https://site.com/page;jsessionid=1?b=2&a=1", "https://site.com/page;jsessionid=1?a=1&b=2"},
+		{"invalid URL kept", TRACKING_PARAMS, false, "https://site.com/%zz?utm_source=x", "https://site.com/%zz?utm_source=x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rewriter, rewriterError := newQueryRewriter(test.patterns, test.sortQuery)
+			if rewriterError != nil {
+				t.Fatalf("newQueryRewriter(%q) failed: %s", test.patterns, rewriterError)
+			}
+			if got := rewriter.rewrite(test.link); got != test.want {
+				t.Errorf("rewrite(%q) = %q, want %q", test.link, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewQueryRewriter(t *testing.T) {
+	if rewriter, _ := newQueryRewriter(nil, false); rewriter != nil {
+		t.Errorf("newQueryRewriter() without patterns or sorting = %+v, want nil", rewriter)
+	}
+	if _, rewriterError := newQueryRewriter([]string{"utm_["}, false); rewriterError == nil {
+		t.Error("newQueryRewriter() with an invalid pattern succeeded, want an error")
+	}
+}
@@ -0,0 +1,361 @@
+package linkhealth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gocolly/colly"
+)
+
+// Links kept in memory while waiting to be requested, see Options.QueueSize
+const DEFAULT_QUEUE_SIZE = 10000
+
+// Context key marking requests that reached the OnRequest callbacks, whose failures are reported by OnError
+const REQUESTED_CONTEXT_KEY = "requested"
+
+// A link waiting to be requested
+type queuedLink struct {
+	Link  string `json:"link"`
+	Depth int    `json:"depth"`
+	// The page the link was found on, empty for starting URLs and sitemap pages
+	Page string `json:"page,omitempty"`
+	// Whether the link was claimed in the visited tracker when it was found
+	Claimed bool `json:"claimed,omitempty"`
+	// Starting URLs that cannot be requested are reported as broken
+	Seed bool `json:"seed,omitempty"`
+}
+
+// Creates the request of the link with a fresh context, at the depth the link was found at
+func (queued queuedLink) request(collector *colly.Collector) (*colly.Request, error) {
+	serialized, marshalError := json.Marshal(struct {
+		URL    string
+		Method string
+	}{queued.Link, "GET"})
+	if marshalError != nil {
+		return nil, marshalError
+	}
+	request, unmarshalError := collector.UnmarshalRequest(serialized)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	request.Depth = queued.Depth
+	request.Headers = &http.Header{"User-Agent": []string{collector.UserAgent}}
+	return request, nil
+}
+
+// The links waiting to be requested by a fixed number of workers. Up to size links are kept in memory,
+// links found while the queue is full wait in a temporary file, so memory does not grow with the frontier
+// of large sites. Links pushed from outside the workers, such as sitemap pages, wait for space instead.
+type linkQueue struct {
+	lock    sync.Mutex
+	changed *sync.Cond
+	size    int
+	memory  []queuedLink
+	// Links spilled to the overflow file, read back once the links in memory are requested
+	overflow *queueOverflow
+	spilled  int
+	// Links queued or being requested, and the link each worker is requesting
+	pending int
+	active  []*queuedLink
+	// Links are pushed from outside the workers until finish is called
+	finished bool
+	progress *progressCounters
+	visit    func(queued queuedLink)
+	workers  sync.WaitGroup
+	stopped  chan struct{}
+	debugf   func(format string, args ...interface{})
+}
+
+func newLinkQueue(options Options, progress *progressCounters) *linkQueue {
+	queue := &linkQueue{size: options.QueueSize, progress: progress, debugf: options.debugf, stopped: make(chan struct{})}
+	queue.changed = sync.NewCond(&queue.lock)
+	return queue
+}
+
+// Sets the function workers request links with, before starting them
+func (queue *linkQueue) handle(visit func(queued queuedLink)) {
+	queue.visit = visit
+}
+
+// Starts the workers, which request links until the queue is finished and empty. Once the context is
+// cancelled the workers stop, leaving the remaining links and the links being requested in the queue.
+func (queue *linkQueue) start(ctx context.Context, workers int) {
+	queue.active = make([]*queuedLink, workers)
+	for worker := 0; worker < workers; worker++ {
+		worker := worker
+		queue.workers.Add(1)
+		go func() {
+			defer queue.workers.Done()
+			for {
+				queued, ok := queue.pop(ctx, worker)
+				if !ok {
+					return
+				}
+				queue.visit(queued)
+				// Requests aborted by the cancellation stay active, so they are requested when resuming
+				if ctx.Err() != nil {
+					return
+				}
+				queue.done(worker)
+			}
+		}()
+	}
+
+	// Wakes up waiting workers and producers once the context is cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			queue.lock.Lock()
+			queue.changed.Broadcast()
+			queue.lock.Unlock()
+		case <-queue.stopped:
+		}
+	}()
+}
+
+// Queues a link found by a worker, never blocking so workers cannot wait on each other
+func (queue *linkQueue) push(queued queuedLink) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.pending++
+	queue.add(queued)
+	queue.changed.Broadcast()
+}
+
+// Queues a link from outside the workers, waiting while the queue is full. Returns false when the
+// context is cancelled first.
+func (queue *linkQueue) pushWait(ctx context.Context, queued queuedLink) bool {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for queue.length() >= queue.size && ctx.Err() == nil {
+		queue.changed.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	queue.pending++
+	queue.add(queued)
+	queue.changed.Broadcast()
+	return true
+}
+
+// Adds a link to memory, or to the overflow file once memory is full or links are already waiting in it
+func (queue *linkQueue) add(queued queuedLink) {
+	defer queue.reportLength()
+	if len(queue.memory) < queue.size && queue.spilled == 0 {
+		queue.memory = append(queue.memory, queued)
+		return
+	}
+
+	if queue.overflow == nil {
+		overflow, overflowError := newQueueOverflow()
+		if overflowError != nil {
+			queue.debugf("Keeping %s in memory, could not create the queue overflow file: %s", queued.Link, overflowError)
+			queue.memory = append(queue.memory, queued)
+			return
+		}
+		queue.overflow = overflow
+	}
+	if writeError := queue.overflow.write(queued); writeError != nil {
+		queue.debugf("Keeping %s in memory, could not write to the queue overflow file: %s", queued.Link, writeError)
+		queue.memory = append(queue.memory, queued)
+		return
+	}
+	queue.spilled++
+}
+
+// Takes the next link, blocking until one is queued. Returns false once the queue is finished and empty,
+// or the context is cancelled.
+func (queue *linkQueue) pop(ctx context.Context, worker int) (queuedLink, bool) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for {
+		if ctx.Err() != nil {
+			return queuedLink{}, false
+		}
+		if len(queue.memory) == 0 && queue.spilled > 0 {
+			queue.refill()
+		}
+		if len(queue.memory) > 0 {
+			queued := queue.memory[0]
+			queue.memory[0] = queuedLink{}
+			queue.memory = queue.memory[1:]
+			queue.active[worker] = &queued
+			queue.reportLength()
+			queue.changed.Broadcast()
+			return queued, true
+		}
+		if queue.finished && queue.pending == 0 {
+			return queuedLink{}, false
+		}
+		queue.changed.Wait()
+	}
+}
+
+// Reads spilled links back into memory
+func (queue *linkQueue) refill() {
+	count := queue.spilled
+	if count > queue.size {
+		count = queue.size
+	}
+	links, readError := queue.overflow.read(count)
+	if readError != nil {
+		// The links cannot be read back, drop them rather than blocking the crawl
+		queue.debugf("Dropping %d queued links, could not read the queue overflow file: %s", queue.spilled, readError)
+		queue.pending -= queue.spilled
+		queue.spilled = 0
+		return
+	}
+	queue.memory = append(queue.memory[:0], links...)
+	queue.spilled -= len(links)
+}
+
+// Marks the link popped by the worker as requested
+func (queue *linkQueue) done(worker int) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	queue.active[worker] = nil
+	queue.pending--
+	queue.changed.Broadcast()
+}
+
+// Marks that no more links are pushed from outside the workers, and waits for the workers to empty the queue
+func (queue *linkQueue) finish() {
+	queue.lock.Lock()
+	queue.finished = true
+	queue.changed.Broadcast()
+	queue.lock.Unlock()
+	queue.workers.Wait()
+	close(queue.stopped)
+}
+
+// Returns every link waiting or being requested, in the order they were queued as far as possible
+func (queue *linkQueue) snapshot() ([]queuedLink, error) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	var links []queuedLink
+	for _, active := range queue.active {
+		if active != nil {
+			links = append(links, *active)
+		}
+	}
+	links = append(links, queue.memory...)
+	if queue.spilled > 0 {
+		spilled, readError := queue.overflow.remaining(queue.spilled)
+		if readError != nil {
+			return nil, readError
+		}
+		links = append(links, spilled...)
+	}
+	return links, nil
+}
+
+// Removes the overflow file
+func (queue *linkQueue) close() {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.overflow != nil {
+		queue.overflow.close()
+		queue.overflow = nil
+	}
+}
+
+// Returns the number of links waiting, with the lock held
+func (queue *linkQueue) length() int {
+	return len(queue.memory) + queue.spilled
+}
+
+func (queue *linkQueue) reportLength() {
+	queue.progress.queue(queue.length())
+}
+
+// A temporary file links are appended to as JSON lines and read back in order
+type queueOverflow struct {
+	file    *os.File
+	reader  *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	decoder *json.Decoder
+	// Links read back so far
+	consumed int
+}
+
+func newQueueOverflow() (*queueOverflow, error) {
+	file, createError := ioutil.TempFile("", "simple_link_health-queue-")
+	if createError != nil {
+		return nil, createError
+	}
+	reader, openError := os.Open(file.Name())
+	if openError != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, openError
+	}
+
+	writer := bufio.NewWriter(file)
+	return &queueOverflow{
+		file:    file,
+		reader:  reader,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+		decoder: json.NewDecoder(reader),
+	}, nil
+}
+
+func (overflow *queueOverflow) write(queued queuedLink) error {
+	return overflow.encoder.Encode(queued)
+}
+
+// Reads the next count links. Only links that were written are read, so the end of the file is never reached
+// while links are still being appended.
+func (overflow *queueOverflow) read(count int) ([]queuedLink, error) {
+	if flushError := overflow.writer.Flush(); flushError != nil {
+		return nil, flushError
+	}
+	links := make([]queuedLink, 0, count)
+	for len(links) < count {
+		var queued queuedLink
+		if decodeError := overflow.decoder.Decode(&queued); decodeError != nil {
+			return links, decodeError
+		}
+		links = append(links, queued)
+		overflow.consumed++
+	}
+	return links, nil
+}
+
+// Returns the count links written but not read back yet, without reading them back
+func (overflow *queueOverflow) remaining(count int) ([]queuedLink, error) {
+	if flushError := overflow.writer.Flush(); flushError != nil {
+		return nil, flushError
+	}
+	file, openError := os.Open(overflow.file.Name())
+	if openError != nil {
+		return nil, openError
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	links := make([]queuedLink, 0, count)
+	for line := 0; line < overflow.consumed+count; line++ {
+		var queued queuedLink
+		if decodeError := decoder.Decode(&queued); decodeError != nil {
+			return nil, decodeError
+		}
+		if line >= overflow.consumed {
+			links = append(links, queued)
+		}
+	}
+	return links, nil
+}
+
+func (overflow *queueOverflow) close() {
+	overflow.reader.Close()
+	overflow.file.Close()
+	os.Remove(overflow.file.Name())
+}
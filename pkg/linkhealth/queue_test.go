@@ -0,0 +1,175 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestQueue(size int) *linkQueue {
+	return newLinkQueue(Options{QueueSize: size}, newProgressCounters())
+}
+
+func queuedLinks(queued []queuedLink) []string {
+	links := make([]string, 0, len(queued))
+	for _, link := range queued {
+		links = append(links, link.Link)
+	}
+	return links
+}
+
+func TestLinkQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		links       int
+		wantMemory  int
+		wantSpilled int
+	}{
+		{"fits in memory", 10, 5, 5, 0},
+		{"exactly full", 5, 5, 5, 0},
+		{"spills the rest", 3, 10, 3, 7},
+		{"single slot", 1, 4, 1, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := newTestQueue(test.size)
+			defer queue.close()
+			var want []string
+			for index := 0; index < test.links; index++ {
+				link := fmt.Sprintf("http://site.com/%d", index)
+				queue.push(queuedLink{Link: link, Depth: 2})
+				want = append(want, link)
+			}
+
+			if len(queue.memory) != test.wantMemory || queue.spilled != test.wantSpilled {
+				t.Errorf("queue has %d links in memory and %d spilled, want %d and %d", len(queue.memory), queue.spilled, test.wantMemory, test.wantSpilled)
+			}
+			if (queue.overflow != nil) != (test.wantSpilled > 0) {
+				t.Errorf("overflow file created = %t, want %t", queue.overflow != nil, test.wantSpilled > 0)
+			}
+			snapshot, snapshotError := queue.snapshot()
+			if snapshotError != nil {
+				t.Fatalf("snapshot() failed: %s", snapshotError)
+			}
+			if got := queuedLinks(snapshot); !reflect.DeepEqual(got, want) {
+				t.Errorf("snapshot() = %q, want %q", got, want)
+			}
+
+			// Spilled links are read back in order, the whole queue is popped first in first out
+			var got []string
+			queue.active = make([]*queuedLink, 1)
+			for index := 0; index < test.links; index++ {
+				queued, ok := queue.pop(context.Background(), 0)
+				if !ok {
+					t.Fatalf("pop() returned no link after %d links", index)
+				}
+				if queued.Depth != 2 {
+					t.Errorf("pop() depth = %d, want 2", queued.Depth)
+				}
+				got = append(got, queued.Link)
+				queue.done(0)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("popped %q, want %q", got, want)
+			}
+			if queue.length() != 0 || queue.pending != 0 {
+				t.Errorf("queue has %d links waiting and %d pending once popped, want none", queue.length(), queue.pending)
+			}
+		})
+	}
+}
+
+func TestLinkQueueWorkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		children int
+	}{
+		{"in memory", 100, 3},
+		{"overflowing", 2, 3},
+		{"single slot", 1, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The order links are requested in with a single worker, each page linking to children pages
+			// until depth 3
+			var want []string
+			frontier := []queuedLink{{Link: "http://site.com/", Depth: 1}}
+			for len(frontier) > 0 {
+				queued := frontier[0]
+				frontier = frontier[1:]
+				want = append(want, queued.Link)
+				for child := 0; queued.Depth < 3 && child < test.children; child++ {
+					frontier = append(frontier, queuedLink{Link: fmt.Sprintf("%s%d/", queued.Link, child), Depth: queued.Depth + 1})
+				}
+			}
+
+			queue := newTestQueue(test.size)
+			defer queue.close()
+			var got []string
+			queue.handle(func(queued queuedLink) {
+				got = append(got, queued.Link)
+				for child := 0; queued.Depth < 3 && child < test.children; child++ {
+					queue.push(queuedLink{Link: fmt.Sprintf("%s%d/", queued.Link, child), Depth: queued.Depth + 1})
+				}
+			})
+			queue.start(context.Background(), 1)
+			if !queue.pushWait(context.Background(), queuedLink{Link: "http://site.com/", Depth: 1}) {
+				t.Fatal("pushWait() failed")
+			}
+			queue.finish()
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("requested %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLinkQueuePushWaitCancelled(t *testing.T) {
+	queue := newTestQueue(1)
+	defer queue.close()
+	queue.push(queuedLink{Link: "http://site.com/a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pushed := make(chan bool)
+	go func() {
+		pushed <- queue.pushWait(ctx, queuedLink{Link: "http://site.com/b"})
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("pushWait() returned while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The cancellation is broadcast by the started queue
+	queue.handle(func(queuedLink) {})
+	cancel()
+	queue.start(ctx, 1)
+	select {
+	case ok := <-pushed:
+		if ok {
+			t.Error("pushWait() = true once cancelled, want false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pushWait() is still waiting once cancelled")
+	}
+}
+
+func TestLinkQueueCloseRemovesOverflow(t *testing.T) {
+	queue := newTestQueue(1)
+	queue.push(queuedLink{Link: "http://site.com/a"})
+	queue.push(queuedLink{Link: "http://site.com/b"})
+	if queue.overflow == nil {
+		t.Fatal("no overflow file created for a full queue")
+	}
+	path := queue.overflow.file.Name()
+	queue.close()
+	if _, statError := os.Stat(path); !os.IsNotExist(statError) {
+		t.Errorf("overflow file %s still exists after close(): %v", path, statError)
+	}
+}
@@ -0,0 +1,129 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Units of a rate limit such as 2/s, in the time each covers
+var RATE_LIMIT_UNITS = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// Politeness limits of the hosts matching a pattern
+type HostLimit struct {
+	// Host name, * matches any part of it, e.g. *.example.com. The port is ignored
+	Host string
+	// Requests per second to each matching host, no limit when 0
+	Rate float64
+	// Parallel requests to all matching hosts together, the Threads or ExternalThreads limit applies when 0
+	Parallelism int
+}
+
+// Parses a rate limit given as host=requests/unit, e.g. docs.example.com=2/s or *=30/m
+func ParseRateLimit(value string) (HostLimit, error) {
+	host, limit, parseError := splitHostLimit(value)
+	if parseError != nil {
+		return HostLimit{}, fmt.Errorf("Invalid rate limit %q, expected host=requests/unit such as docs.example.com=2/s", value)
+	}
+
+	parts := strings.SplitN(limit, "/", 2)
+	requests, numberError := strconv.ParseFloat(parts[0], 64)
+	unit, knownUnit := RATE_LIMIT_UNITS["s"], true
+	if len(parts) == 2 {
+		unit, knownUnit = RATE_LIMIT_UNITS[parts[1]]
+	}
+	if numberError != nil || requests <= 0 || !knownUnit {
+		return HostLimit{}, fmt.Errorf("Invalid rate limit %q, expected host=requests/unit such as docs.example.com=2/s", value)
+	}
+	return HostLimit{Host: host, Rate: requests / unit.Seconds()}, nil
+}
+
+// Parses a parallelism limit given as host=requests, e.g. cdn.example.com=2
+func ParseHostThreads(value string) (HostLimit, error) {
+	host, limit, parseError := splitHostLimit(value)
+	parallelism, numberError := strconv.Atoi(limit)
+	if parseError != nil || numberError != nil || parallelism < 1 {
+		return HostLimit{}, fmt.Errorf("Invalid host threads %q, expected host=requests such as cdn.example.com=2", value)
+	}
+	return HostLimit{Host: host, Parallelism: parallelism}, nil
+}
+
+func splitHostLimit(value string) (string, string, error) {
+	separator := strings.LastIndex(value, "=")
+	if separator <= 0 {
+		return "", "", fmt.Errorf("Missing host")
+	}
+	return strings.ToLower(strings.TrimSpace(value[:separator])), strings.TrimSpace(value[separator+1:]), nil
+}
+
+// Returns the regular expression matching the hosts of the pattern, with or without a port
+func hostPatternRegexp(pattern string) string {
+	return "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, `[^:]*`, -1) + `(:\d+)?$`
+}
+
+// Spaces the requests to each host by its rate limit. Each host matching a pattern gets its own rate.
+type rateLimiter struct {
+	limits   []HostLimit
+	patterns []*regexp.Regexp
+	lock     sync.Mutex
+	// Earliest time the next request to each host may be made
+	next map[string]time.Time
+}
+
+func newRateLimiter(limits []HostLimit) *rateLimiter {
+	limiter := &rateLimiter{next: make(map[string]time.Time)}
+	for _, limit := range limits {
+		if limit.Rate > 0 {
+			limiter.limits = append(limiter.limits, limit)
+			limiter.patterns = append(limiter.patterns, regexp.MustCompile(hostPatternRegexp(limit.Host)))
+		}
+	}
+	return limiter
+}
+
+// Returns the time between requests to the host, 0 when it has no rate limit. The first matching limit applies.
+func (limiter *rateLimiter) interval(host string) time.Duration {
+	for index, pattern := range limiter.patterns {
+		if pattern.MatchString(host) {
+			return time.Duration(float64(time.Second) / limiter.limits[index].Rate)
+		}
+	}
+	return 0
+}
+
+// Waits until a request to the link may be made, returning false when the context was cancelled first
+func (limiter *rateLimiter) wait(ctx context.Context, link *url.URL) bool {
+	host := strings.ToLower(link.Hostname())
+	interval := limiter.interval(host)
+	if interval <= 0 {
+		return true
+	}
+
+	// Reserve the next slot, so parallel requests to the host are spaced by the interval
+	limiter.lock.Lock()
+	now := time.Now()
+	slot := limiter.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	limiter.next[host] = slot.Add(interval)
+	limiter.lock.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
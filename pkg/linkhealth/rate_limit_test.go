@@ -0,0 +1,96 @@
+package linkhealth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  HostLimit
+	}{
+		{"docs.example.com=2/s", HostLimit{Host: "docs.example.com", Rate: 2}},
+		{"*=30/m", HostLimit{Host: "*", Rate: 0.5}},
+		{"*.Example.com=360/h", HostLimit{Host: "*.example.com", Rate: 0.1}},
+		{"example.com=4", HostLimit{Host: "example.com", Rate: 4}},
+		{" example.com = 0.5/s ", HostLimit{Host: "example.com", Rate: 0.5}},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, parseError := ParseRateLimit(test.value)
+			if parseError != nil {
+				t.Fatalf("ParseRateLimit(%q) failed: %s", test.value, parseError)
+			}
+			if got != test.want {
+				t.Errorf("ParseRateLimit(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitInvalid(t *testing.T) {
+	for _, value := range []string{"", "2/s", "=2/s", "example.com=", "example.com=fast", "example.com=0/s", "example.com=-1/s", "example.com=2/d", "example.com=2/"} {
+		if _, parseError := ParseRateLimit(value); parseError == nil {
+			t.Errorf("ParseRateLimit(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestParseHostThreads(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    HostLimit
+		invalid bool
+	}{
+		{value: "cdn.example.com=2", want: HostLimit{Host: "cdn.example.com", Parallelism: 2}},
+		{value: "*.Example.com=1", want: HostLimit{Host: "*.example.com", Parallelism: 1}},
+		{value: "cdn.example.com=0", invalid: true},
+		{value: "cdn.example.com=1.5", invalid: true},
+		{value: "=2", invalid: true},
+		{value: "2", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, parseError := ParseHostThreads(test.value)
+			switch {
+			case test.invalid && parseError == nil:
+				t.Errorf("ParseHostThreads(%q) succeeded, want an error", test.value)
+			case !test.invalid && parseError != nil:
+				t.Errorf("ParseHostThreads(%q) failed: %s", test.value, parseError)
+			case !test.invalid && got != test.want:
+				t.Errorf("ParseHostThreads(%q) = %+v, want %+v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterInterval(t *testing.T) {
+	limiter := newRateLimiter([]HostLimit{
+		{Host: "docs.example.com", Rate: 2},
+		{Host: "cdn.example.com", Parallelism: 4},
+		{Host: "*.example.com", Rate: 0.5},
+		{Host: "*", Rate: 10},
+	})
+	tests := []struct {
+		host string
+		want time.Duration
+	}{
+		{"docs.example.com", 500 * time.Millisecond},
+		{"docs.example.com:8080", 500 * time.Millisecond},
+		{"www.example.com", 2 * time.Second},
+		// Limits without a rate are skipped
+		{"cdn.example.com", 2 * time.Second},
+		{"example.com", 100 * time.Millisecond},
+		{"other.org", 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := limiter.interval(test.host); got != test.want {
+			t.Errorf("interval(%q) = %s, want %s", test.host, got, test.want)
+		}
+	}
+
+	if got := newRateLimiter(nil).interval("example.com"); got != 0 {
+		t.Errorf("interval() without limits = %s, want 0", got)
+	}
+}
@@ -0,0 +1,93 @@
+package linkhealth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Matches the redirect limit of Go's http client
+const DEFAULT_MAX_REDIRECTS = 10
+
+// A hop of a redirect chain, the URL requested, the redirect status it responded with and the URL redirected to
+type Redirect struct {
+	URL      string
+	Status   int
+	Location string
+}
+
+// Formats redirect chains as "http://a 301 -> http://b 302 -> http://c"
+func FormatRedirects(redirects []Redirect) string {
+	if len(redirects) == 0 {
+		return ""
+	}
+	hops := make([]string, 0, len(redirects)+1)
+	for _, redirect := range redirects {
+		hops = append(hops, fmt.Sprintf("%s %d", redirect.URL, redirect.Status))
+	}
+	hops = append(hops, redirects[len(redirects)-1].Location)
+	return strings.Join(hops, " -> ")
+}
+
+// Records the redirect chain of every request, keyed by the URL the request was made for
+type redirectTracker struct {
+	lock   sync.Mutex
+	chains map[string][]Redirect
+}
+
+func newRedirectTracker() *redirectTracker {
+	return &redirectTracker{chains: make(map[string][]Redirect)}
+}
+
+// Records the hop leading to the request, called by the http client before following a redirect.
+// Returns an error stopping the chain when it loops or exceeds the redirect limit.
+func (tracker *redirectTracker) redirected(request *http.Request, via []*http.Request, maxRedirects int) error {
+	previous := via[len(via)-1]
+	hop := Redirect{URL: previous.URL.String(), Location: request.URL.String()}
+	if request.Response != nil {
+		hop.Status = request.Response.StatusCode
+	}
+
+	tracker.lock.Lock()
+	link := via[0].URL.String()
+	chain := append(tracker.chains[link], hop)
+	tracker.chains[link] = chain
+	tracker.lock.Unlock()
+
+	for _, visited := range via {
+		if visited.URL.String() == request.URL.String() {
+			return withCategory(ERROR_CATEGORY_TOO_MANY_REDIRECTS, fmt.Errorf("Redirect loop: %s", FormatRedirects(chain)))
+		}
+	}
+	if len(via) > maxRedirects {
+		return withCategory(ERROR_CATEGORY_TOO_MANY_REDIRECTS, fmt.Errorf("Stopped after %d redirects: %s", maxRedirects, FormatRedirects(chain)))
+	}
+	return nil
+}
+
+// Returns and forgets the redirect chain of the request made for the link
+func (tracker *redirectTracker) chain(link string) []Redirect {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	chain := tracker.chains[link]
+	delete(tracker.chains, link)
+	return chain
+}
+
+// Checks whether the first hop of the chain is a permanent redirect within the same host,
+// meaning the link should be updated to the URL redirected to
+func isPermanentSameHostRedirect(chain []Redirect) bool {
+	if len(chain) == 0 {
+		return false
+	}
+	first := chain[0]
+	if first.Status != http.StatusMovedPermanently && first.Status != http.StatusPermanentRedirect {
+		return false
+	}
+
+	firstURL, firstError := url.Parse(first.URL)
+	locationURL, locationError := url.Parse(first.Location)
+	return firstError == nil && locationError == nil && firstURL.Host == locationURL.Host
+}
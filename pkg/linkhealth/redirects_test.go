@@ -0,0 +1,157 @@
+package linkhealth
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatRedirects(t *testing.T) {
+	tests := []struct {
+		name      string
+		redirects []Redirect
+		want      string
+	}{
+		{"no redirects", nil, ""},
+		{"single hop", []Redirect{
+			{URL: "http://a", Status: 301, Location: "http://b"},
+		}, "http://a 301 -> http://b"},
+		{"chain", []Redirect{
+			{URL: "http://a", Status: 301, Location: "http://b"},
+			{URL: "http://b", Status: 302, Location: "http://c"},
+		}, "http://a 301 -> http://b 302 -> http://c"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := FormatRedirects(test.redirects); got != test.want {
+				t.Errorf("FormatRedirects() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// Follows the redirects from the first URL to the others like the http client, returning the error of the
+// first hop stopping the chain
+func followRedirects(t *testing.T, tracker *redirectTracker, urls []string, status int, maxRedirects int) error {
+	var via []*http.Request
+	for index, link := range urls {
+		request, requestError := http.NewRequest("GET", link, nil)
+		if requestError != nil {
+			t.Fatal(requestError)
+		}
+		if index > 0 {
+			request.Response = &http.Response{StatusCode: status, Request: via[len(via)-1]}
+			if redirectError := tracker.redirected(request, via, maxRedirects); redirectError != nil {
+				return redirectError
+			}
+		}
+		via = append(via, request)
+	}
+	return nil
+}
+
+func TestRedirectTrackerRedirected(t *testing.T) {
+	tests := []struct {
+		name         string
+		urls         []string
+		maxRedirects int
+		// Start of the error message, empty when the chain is followed to the end
+		wantError string
+		wantChain string
+	}{
+		{
+			name:         "chain",
+			urls:         []string{"http://site.com/a", "http://site.com/b", "http://site.com/c"},
+			maxRedirects: DEFAULT_MAX_REDIRECTS,
+			wantChain:    "http://site.com/a 301 -> http://site.com/b 301 -> http://site.com/c",
+		},
+		{
+			name:         "loop",
+			urls:         []string{"http://site.com/a", "http://site.com/b", "http://site.com/a"},
+			maxRedirects: DEFAULT_MAX_REDIRECTS,
+			wantError:    "Redirect loop: http://site.com/a 301 -> http://site.com/b 301 -> http://site.com/a",
+			wantChain:    "http://site.com/a 301 -> http://site.com/b 301 -> http://site.com/a",
+		},
+		{
+			name:         "redirect to itself",
+			urls:         []string{"http://site.com/a", "http://site.com/a"},
+			maxRedirects: DEFAULT_MAX_REDIRECTS,
+			wantError:    "Redirect loop: http://site.com/a 301 -> http://site.com/a",
+			wantChain:    "http://site.com/a 301 -> http://site.com/a",
+		},
+		{
+			name:         "at the limit",
+			urls:         []string{"http://site.com/1", "http://site.com/2", "http://site.com/3"},
+			maxRedirects: 2,
+			wantChain:    "http://site.com/1 301 -> http://site.com/2 301 -> http://site.com/3",
+		},
+		{
+			name:         "over the limit",
+			urls:         []string{"http://site.com/1", "http://site.com/2", "http://site.com/3", "http://site.com/4"},
+			maxRedirects: 2,
+			wantError:    "Stopped after 2 redirects: http://site.com/1 301 -> http://site.com/2 301 -> http://site.com/3 301 -> http://site.com/4",
+			wantChain:    "http://site.com/1 301 -> http://site.com/2 301 -> http://site.com/3 301 -> http://site.com/4",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newRedirectTracker()
+			redirectError := followRedirects(t, tracker, test.urls, http.StatusMovedPermanently, test.maxRedirects)
+			switch {
+			case test.wantError == "" && redirectError != nil:
+				t.Errorf("redirected() failed: %s", redirectError)
+			case test.wantError != "" && redirectError == nil:
+				t.Errorf("redirected() succeeded, want error %q", test.wantError)
+			case test.wantError != "" && !strings.HasPrefix(redirectError.Error(), test.wantError):
+				t.Errorf("redirected() error = %q, want %q", redirectError, test.wantError)
+			case test.wantError != "" && CategorizeError(redirectError, 0) != ERROR_CATEGORY_TOO_MANY_REDIRECTS:
+				t.Errorf("redirected() error category = %q, want %q", CategorizeError(redirectError, 0), ERROR_CATEGORY_TOO_MANY_REDIRECTS)
+			}
+
+			// The chain is recorded under the URL the redirects started from, and forgotten once returned
+			if got := FormatRedirects(tracker.chain(test.urls[0])); got != test.wantChain {
+				t.Errorf("chain() = %q, want %q", got, test.wantChain)
+			}
+			if chain := tracker.chain(test.urls[0]); chain != nil {
+				t.Errorf("chain() after it was returned = %v, want none", chain)
+			}
+		})
+	}
+}
+
+func TestIsPermanentSameHostRedirect(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain []Redirect
+		want  bool
+	}{
+		{"no redirects", nil, false},
+		{"moved permanently", []Redirect{{URL: "http://site.com/a", Status: 301, Location: "http://site.com/b"}}, true},
+		{"permanent redirect", []Redirect{{URL: "http://site.com/a", Status: 308, Location: "http://site.com/b"}}, true},
+		{"temporary redirect", []Redirect{{URL: "http://site.com/a", Status: 302, Location: "http://site.com/b"}}, false},
+		{"other host", []Redirect{{URL: "http://site.com/a", Status: 301, Location: "http://www.site.com/a"}}, false},
+		{"only the first hop counts", []Redirect{
+			{URL: "http://site.com/a", Status: 302, Location: "http://site.com/b"},
+			{URL: "http://site.com/b", Status: 301, Location: "http://site.com/c"},
+		}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isPermanentSameHostRedirect(test.chain); got != test.want {
+				t.Errorf("isPermanentSameHostRedirect(%v) = %t, want %t", test.chain, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedirectTrackerStatus(t *testing.T) {
+	tracker := newRedirectTracker()
+	if redirectError := followRedirects(t, tracker, []string{"http://site.com/a", "http://site.com/b"}, http.StatusFound, DEFAULT_MAX_REDIRECTS); redirectError != nil {
+		t.Fatal(redirectError)
+	}
+	want := []Redirect{{URL: "http://site.com/a", Status: http.StatusFound, Location: "http://site.com/b"}}
+	if got := tracker.chain("http://site.com/a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("chain() = %v, want %v", got, want)
+	}
+}
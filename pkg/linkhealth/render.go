@@ -0,0 +1,148 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// How the links of a page are extracted
+const (
+	// Parse the HTML as served, the default
+	RENDER_HTML = "html"
+	// Render the page in a headless browser first, so links added by JavaScript are found
+	RENDER_BROWSER = "browser"
+)
+
+var RENDER_MODES = []string{RENDER_HTML, RENDER_BROWSER}
+
+// Time given to scripts to add their links once a page loaded
+const RENDER_SETTLE_TIME = 500 * time.Millisecond
+
+// Renders pages in tabs of a headless Chrome or Chromium, at most one page per tab at a time.
+// Tabs are opened as needed and reused, up to the number of threads.
+type browserRenderer struct {
+	browser context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	// Holds a token for every tab in use, bounding the number of tabs
+	slots chan struct{}
+	// Tabs not rendering a page
+	idle chan *browserTab
+}
+
+type browserTab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Starts the browser, returning an error when no Chrome or Chromium could be started
+func newBrowserRenderer(options Options) (*browserRenderer, error) {
+	allocatorOptions := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.UserAgent(options.UserAgent))
+	if options.Insecure {
+		allocatorOptions = append(allocatorOptions, chromedp.Flag("ignore-certificate-errors", true))
+	}
+	allocator, cancelAllocator := chromedp.NewExecAllocator(context.Background(), allocatorOptions...)
+	browser, cancelBrowser := chromedp.NewContext(allocator)
+	renderer := &browserRenderer{
+		browser: browser,
+		cancel: func() {
+			cancelBrowser()
+			cancelAllocator()
+		},
+		timeout: options.Timeout,
+		slots:   make(chan struct{}, options.Threads),
+		idle:    make(chan *browserTab, options.Threads),
+	}
+
+	// Running without actions starts the browser
+	if startError := chromedp.Run(browser); startError != nil {
+		renderer.cancel()
+		return nil, fmt.Errorf("Could not start a browser for rendering, is Chrome or Chromium installed? %s", startError)
+	}
+	return renderer, nil
+}
+
+// Loads the page in a tab with the cookies of the crawl, returning its HTML once scripts had time to run
+func (renderer *browserRenderer) render(ctx context.Context, link string, cookies []*http.Cookie) ([]byte, error) {
+	select {
+	case renderer.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-renderer.slots }()
+
+	tab, tabError := renderer.tab()
+	if tabError != nil {
+		return nil, tabError
+	}
+	renderCtx, cancel := context.WithTimeout(tab.ctx, renderer.timeout+RENDER_SETTLE_TIME)
+	defer cancel()
+	// Stop rendering once the crawl is cancelled
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	var html string
+	renderError := chromedp.Run(renderCtx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			for _, cookie := range cookies {
+				if _, cookieError := network.SetCookie(cookie.Name, cookie.Value).WithURL(link).Do(ctx); cookieError != nil {
+					return cookieError
+				}
+			}
+			return nil
+		}),
+		chromedp.Navigate(link),
+		chromedp.Sleep(RENDER_SETTLE_TIME),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if renderError != nil {
+		// The tab may still be loading the page, so it is closed rather than reused
+		tab.cancel()
+		return nil, renderError
+	}
+
+	renderer.idle <- tab
+	return []byte(html), nil
+}
+
+// Returns an idle tab, opening a new one when every open tab is in use
+func (renderer *browserRenderer) tab() (*browserTab, error) {
+	select {
+	case tab := <-renderer.idle:
+		return tab, nil
+	default:
+	}
+
+	ctx, cancel := chromedp.NewContext(renderer.browser)
+	// Opened without a timeout, as the first run of a context closes the tab once its context is done
+	if openError := chromedp.Run(ctx); openError != nil {
+		cancel()
+		return nil, openError
+	}
+	return &browserTab{ctx: ctx, cancel: cancel}, nil
+}
+
+// Closes every tab and stops the browser
+func (renderer *browserRenderer) close() {
+	for {
+		select {
+		case tab := <-renderer.idle:
+			tab.cancel()
+		default:
+			renderer.cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package linkhealth
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_DELAY = time.Second
+	// Upper bound of the wait before a retry, including waits requested with Retry-After
+	MAX_RETRY_DELAY = time.Minute
+)
+
+// Statuses signalling a temporary failure worth retrying
+var RETRY_STATUS_CODES = map[int]bool{
+	http.StatusRequestTimeout:     true,
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// Retries requests failing with a transient error or status, waiting with exponential backoff and jitter
+// between attempts. A Retry-After header sent by the server takes precedence over the backoff.
+type retryTransport struct {
+	transport http.RoundTripper
+	retries   int
+	delay     time.Duration
+	lock      sync.Mutex
+	random    *rand.Rand
+	// Called with a message before every retry when set, see Options.Debug
+	debug func(message string)
+}
+
+func newRetryTransport(transport http.RoundTripper, retries int, delay time.Duration, debug func(message string)) *retryTransport {
+	return &retryTransport{
+		transport: transport,
+		retries:   retries,
+		delay:     delay,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		debug:     debug,
+	}
+}
+
+func (retry *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		response, roundTripError := retry.transport.RoundTrip(request.Clone(request.Context()))
+		if attempt >= retry.retries || request.Context().Err() != nil || !shouldRetry(response, roundTripError) {
+			return response, roundTripError
+		}
+
+		wait := retry.backoff(attempt)
+		if response != nil {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+			_, _ = ioutil.ReadAll(response.Body)
+			response.Body.Close()
+		}
+		if wait > MAX_RETRY_DELAY {
+			wait = MAX_RETRY_DELAY
+		}
+		if retry.debug != nil {
+			reason := fmt.Sprint(roundTripError)
+			if response != nil {
+				reason = response.Status
+			}
+			retry.debug(fmt.Sprintf("Retrying %s in %s after %s, attempt %d of %d", request.URL, wait.Round(time.Millisecond), reason, attempt+2, retry.retries+1))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Returns the wait before the retry following the attempt, doubling with every attempt.
+// Half of the wait is random, so links failing together are not retried together.
+func (retry *retryTransport) backoff(attempt int) time.Duration {
+	wait := retry.delay << uint(attempt)
+	if wait <= 0 || wait > MAX_RETRY_DELAY {
+		wait = MAX_RETRY_DELAY
+	}
+
+	retry.lock.Lock()
+	defer retry.lock.Unlock()
+	return wait/2 + time.Duration(retry.random.Int63n(int64(wait/2)+1))
+}
+
+func shouldRetry(response *http.Response, roundTripError error) bool {
+	if roundTripError != nil {
+		return true
+	}
+	return RETRY_STATUS_CODES[response.StatusCode]
+}
+
+// Parses a Retry-After header, given either as a number of seconds or as an HTTP date
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, parseError := strconv.Atoi(header); parseError == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, parseError := http.ParseTime(header); parseError == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
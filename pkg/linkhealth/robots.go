@@ -0,0 +1,150 @@
+package linkhealth
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+const (
+	ROBOTS_PATH = "/robots.txt"
+	// robots.txt files are limited to 500KiB by Google, larger files are truncated
+	MAX_ROBOTS_SIZE = 500 * 1024
+	ROBOTS_TIMEOUT  = 30 * time.Second
+)
+
+// Loads the robots.txt of every host once, deciding which links may be requested and how long to wait
+// between requests to the same host. Hosts whose robots.txt cannot be loaded allow every link.
+type robotsTracker struct {
+	client  *http.Client
+	options Options
+	lock    sync.Mutex
+	hosts   map[string]*robotsHost
+	// Hosts whose Crawl-delay is applied by a limit rule of the collector rather than by waiting
+	delayed map[string]bool
+}
+
+type robotsHost struct {
+	once   sync.Once
+	robots *robotstxt.RobotsData
+	delay  time.Duration
+	// Earliest time the next request to the host may be made
+	lock sync.Mutex
+	next time.Time
+}
+
+func newRobotsTracker(options Options) *robotsTracker {
+	return &robotsTracker{
+		client: &http.Client{
+			Transport: getTransport(newCertificateTracker(), options),
+			Timeout:   ROBOTS_TIMEOUT,
+		},
+		options: options,
+		hosts:   make(map[string]*robotsHost),
+		delayed: make(map[string]bool),
+	}
+}
+
+// Returns the robots.txt rules of the link's host, loading them on first use
+func (tracker *robotsTracker) host(ctx context.Context, link *url.URL) *robotsHost {
+	key := link.Scheme + "://" + link.Host
+	tracker.lock.Lock()
+	host, ok := tracker.hosts[key]
+	if !ok {
+		host = &robotsHost{}
+		tracker.hosts[key] = host
+	}
+	tracker.lock.Unlock()
+
+	host.once.Do(func() {
+		host.robots = tracker.load(ctx, key+ROBOTS_PATH)
+		if host.robots != nil {
+			host.delay = host.robots.FindGroup(tracker.options.UserAgent).CrawlDelay
+		}
+	})
+	return host
+}
+
+func (tracker *robotsTracker) load(ctx context.Context, robotsURL string) *robotstxt.RobotsData {
+	request, requestError := http.NewRequest("GET", robotsURL, nil)
+	if requestError != nil {
+		return nil
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", tracker.options.UserAgent)
+	applyHeaderRules(tracker.options.HeaderRules, request.URL.Hostname(), request.Header)
+
+	response, responseError := tracker.client.Do(request)
+	if responseError != nil {
+		return nil
+	}
+	defer response.Body.Close()
+
+	body, readError := ioutil.ReadAll(io.LimitReader(response.Body, MAX_ROBOTS_SIZE))
+	if readError != nil {
+		return nil
+	}
+	robots, parseError := robotstxt.FromStatusAndBytes(response.StatusCode, body)
+	if parseError != nil {
+		return nil
+	}
+	return robots
+}
+
+// Checks whether robots.txt allows requesting the link
+func (tracker *robotsTracker) allowed(ctx context.Context, link *url.URL) bool {
+	host := tracker.host(ctx, link)
+	if host.robots == nil {
+		return true
+	}
+	return host.robots.TestAgent(link.RequestURI(), tracker.options.UserAgent)
+}
+
+// Returns the Crawl-delay of the link's host, loading its robots.txt on first use
+func (tracker *robotsTracker) crawlDelay(ctx context.Context, link *url.URL) time.Duration {
+	return tracker.host(ctx, link).delay
+}
+
+// Stops waiting for the Crawl-delay of the host, once a limit rule of the collector delays its requests
+func (tracker *robotsTracker) delayedByCollector(host string) {
+	tracker.lock.Lock()
+	tracker.delayed[host] = true
+	tracker.lock.Unlock()
+}
+
+// Waits until the crawl delay of the link's host has passed since the previous request to it.
+// Returns false when the context is cancelled while waiting.
+func (tracker *robotsTracker) wait(ctx context.Context, link *url.URL) bool {
+	tracker.lock.Lock()
+	delayed := tracker.delayed[link.Host]
+	tracker.lock.Unlock()
+	host := tracker.host(ctx, link)
+	if delayed || host.delay <= 0 {
+		return true
+	}
+
+	// Reserve the next slot, so parallel requests to the host are spaced by the delay
+	host.lock.Lock()
+	now := time.Now()
+	slot := host.next
+	if slot.Before(now) {
+		slot = now
+	}
+	host.next = slot.Add(host.delay)
+	host.lock.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
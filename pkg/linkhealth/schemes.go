@@ -0,0 +1,128 @@
+package linkhealth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Schemes of links that are not requested but are not suspicious either
+var KNOWN_SCHEMES = []string{"mailto", "tel", "sms", "ftp", "ftps", "sftp", "ssh", "git", "irc", "magnet", "data", "webcal", "skype", "facetime"}
+
+// Schemes that are warned about even though browsers support them
+var SUSPICIOUS_SCHEMES = []string{"javascript", "vbscript", "file"}
+
+// Phone numbers made of digits and common separators, optionally followed by parameters such as ;ext=123
+var TEL_NUMBER_PATTERN = regexp.MustCompile(`^\+?[0-9()./\- ]+(;[a-zA-Z-]+(=[^;]*)?)*$`)
+
+const (
+	TEL_MIN_DIGITS = 3
+	// The longest number E.164 allows
+	TEL_MAX_DIGITS = 15
+)
+
+// Checks links with schemes other than http and https, which are never requested
+type schemeChecker struct {
+	checkMX bool
+	lock    sync.Mutex
+	// Error of the MX lookup per email domain, nil when it has a mail server
+	domains map[string]error
+}
+
+func newSchemeChecker(checkMX bool) *schemeChecker {
+	return &schemeChecker{checkMX: checkMX, domains: make(map[string]error)}
+}
+
+// Validates a non-HTTP link. Returns a warning when its scheme is suspicious or unknown, or an error
+// when the link is broken.
+func (checker *schemeChecker) check(ctx context.Context, link *url.URL) (string, error) {
+	scheme := strings.ToLower(link.Scheme)
+	switch {
+	case scheme == "mailto":
+		return "", checker.checkMailto(ctx, link)
+	case scheme == "tel":
+		return "", checkTel(link)
+	case containsString(SUSPICIOUS_SCHEMES, scheme):
+		return fmt.Sprintf("Suspicious %s: link", scheme), nil
+	case !containsString(KNOWN_SCHEMES, scheme):
+		return fmt.Sprintf("Unknown link scheme %s:", scheme), nil
+	}
+	return "", nil
+}
+
+// Checks that every address of a mailto link is valid, and that its domain has a mail server when checking MX records
+func (checker *schemeChecker) checkMailto(ctx context.Context, link *url.URL) error {
+	addresses, unescapeError := url.PathUnescape(link.Opaque)
+	if unescapeError != nil {
+		return fmt.Errorf("Invalid mailto link: %s", unescapeError)
+	}
+	// Addresses may also be given in the to parameter, e.g. mailto:?to=someone@example.com
+	if to := link.Query().Get("to"); to != "" {
+		addresses = strings.Trim(addresses+","+to, ",")
+	}
+	if addresses == "" {
+		return fmt.Errorf("Invalid mailto link: no address")
+	}
+
+	for _, address := range strings.Split(addresses, ",") {
+		parsed, parseError := mail.ParseAddress(strings.TrimSpace(address))
+		if parseError != nil {
+			return fmt.Errorf("Invalid email address %q: %s", address, parseError)
+		}
+		domain := parsed.Address[strings.LastIndex(parsed.Address, "@")+1:]
+		if !strings.Contains(domain, ".") {
+			return fmt.Errorf("Invalid email address %q: domain %q has no top level domain", address, domain)
+		}
+		if checker.checkMX {
+			if mxError := checker.lookupMX(ctx, strings.ToLower(domain)); mxError != nil {
+				return mxError
+			}
+		}
+	}
+	return nil
+}
+
+// Looks up the mail servers of the domain once, falling back to its address as mail servers do
+func (checker *schemeChecker) lookupMX(ctx context.Context, domain string) error {
+	checker.lock.Lock()
+	lookupError, ok := checker.domains[domain]
+	checker.lock.Unlock()
+	if ok {
+		return lookupError
+	}
+
+	if records, mxError := net.DefaultResolver.LookupMX(ctx, domain); mxError != nil || len(records) == 0 {
+		if _, hostError := net.DefaultResolver.LookupHost(ctx, domain); hostError != nil {
+			lookupError = fmt.Errorf("No mail server found for %s", domain)
+		}
+	}
+
+	checker.lock.Lock()
+	checker.domains[domain] = lookupError
+	checker.lock.Unlock()
+	return lookupError
+}
+
+// Checks that a tel link is a plausible phone number
+func checkTel(link *url.URL) error {
+	number, unescapeError := url.PathUnescape(link.Opaque)
+	if unescapeError != nil || !TEL_NUMBER_PATTERN.MatchString(number) {
+		return fmt.Errorf("Invalid phone number %q", link.Opaque)
+	}
+
+	digits := 0
+	for _, character := range strings.SplitN(number, ";", 2)[0] {
+		if character >= '0' && character <= '9' {
+			digits++
+		}
+	}
+	if digits < TEL_MIN_DIGITS || digits > TEL_MAX_DIGITS {
+		return fmt.Errorf("Invalid phone number %q: expected %d to %d digits", link.Opaque, TEL_MIN_DIGITS, TEL_MAX_DIGITS)
+	}
+	return nil
+}
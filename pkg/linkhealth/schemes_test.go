@@ -0,0 +1,59 @@
+package linkhealth
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+func TestSchemeCheckerCheck(t *testing.T) {
+	tests := []struct {
+		link        string
+		wantWarning string
+		wantError   bool
+	}{
+		{link: "mailto:someone@example.com"},
+		{link: "mailto:someone@example.com?subject=Hello%20there"},
+		{link: "mailto:first@example.com,second@example.org"},
+		{link: "mailto:?to=someone@example.com"},
+		{link: "mailto:Some%20One%20%3Csomeone@example.com%3E"},
+		{link: "MAILTO:someone@example.com"},
+		{link: "mailto:", wantError: true},
+		{link: "mailto:?subject=Hello", wantError: true},
+		{link: "mailto:someone", wantError: true},
+		{link: "mailto:someone@localhost", wantError: true},
+		{link: "mailto:first@example.com,second", wantError: true},
+		{link: "mailto:some%zzone@example.com", wantError: true},
+		{link: "tel:+1-555-0100"},
+		{link: "tel:+44%2020%207946%200958"},
+		{link: "tel:(555)%20010.0100"},
+		{link: "tel:555-0100;ext=123"},
+		{link: "tel:911"},
+		{link: "tel:12", wantError: true},
+		{link: "tel:+1234567890123456", wantError: true},
+		{link: "tel:555-CALL-NOW", wantError: true},
+		{link: "tel:", wantError: true},
+		{link: "tel:++1555", wantError: true},
+		{link: "sms:+15550100"},
+		{link: "ftp://ftp.example.com/file.txt"},
+		{link: "javascript:void(0)", wantWarning: "Suspicious javascript: link"},
+		{link: "file:///etc/passwd", wantWarning: "Suspicious file: link"},
+		{link: "gopher://example.com", wantWarning: "Unknown link scheme gopher:"},
+	}
+	checker := newSchemeChecker(false)
+	for _, test := range tests {
+		t.Run(test.link, func(t *testing.T) {
+			link, parseError := url.Parse(test.link)
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+			warning, checkError := checker.check(context.Background(), link)
+			if warning != test.wantWarning {
+				t.Errorf("check(%q) warning = %q, want %q", test.link, warning, test.wantWarning)
+			}
+			if (checkError != nil) != test.wantError {
+				t.Errorf("check(%q) error = %v, want error %t", test.link, checkError, test.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package linkhealth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// How serious a result is. Only errors are meant to fail a crawl
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+	SEVERITY_INFO    = "info"
+)
+
+// Kinds of warnings whose severity can be changed, other warnings have the warning severity
+const (
+	// A healthy link took longer than Options.SlowThreshold to respond
+	WARNING_KIND_SLOW = "slow"
+	// A link permanently redirects within the same host, see Options.WarnPermanentRedirects
+	WARNING_KIND_PERMANENT_REDIRECT = "permanent_redirect"
+	// The size of a healthy response is below Options.MinSize or deviates from its size in previous runs
+	WARNING_KIND_SIZE = "size"
+)
+
+// Severities of results by condition. A condition is a status code such as 403, a status class such as
+// 5xx, one of the ERROR_CATEGORY constants or one of the WARNING_KIND constants. Broken links are matched by
+// their status code, then their status class, then their category, and are errors when nothing matches.
+type SeverityRules map[string]string
+
+// Sites blocking bots and rate limits are not worth failing for, outdated links are merely worth knowing about
+var DEFAULT_SEVERITIES = SeverityRules{
+	"403":                           SEVERITY_WARNING,
+	"429":                           SEVERITY_WARNING,
+	WARNING_KIND_SLOW:               SEVERITY_WARNING,
+	WARNING_KIND_PERMANENT_REDIRECT: SEVERITY_INFO,
+	WARNING_KIND_SIZE:               SEVERITY_WARNING,
+}
+
+// Parses condition=severity rules, e.g. 404=warning or slow=error, overriding the DEFAULT_SEVERITIES
+func ParseSeverityRules(rules []string) (SeverityRules, error) {
+	parsed := make(SeverityRules, len(DEFAULT_SEVERITIES)+len(rules))
+	for condition, severity := range DEFAULT_SEVERITIES {
+		parsed[condition] = severity
+	}
+
+	for _, rule := range rules {
+		parts := strings.SplitN(rule, "=", 2)
+		condition := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || !isSeverityCondition(condition) {
+			return nil, fmt.Errorf("Invalid severity rule %q, expected a status code, status class like 5xx, error category or warning kind, followed by =error, =warning or =info", rule)
+		}
+		severity := strings.ToLower(strings.TrimSpace(parts[1]))
+		if severity != SEVERITY_ERROR && severity != SEVERITY_WARNING && severity != SEVERITY_INFO {
+			return nil, fmt.Errorf("Invalid severity %q in rule %q, expected error, warning or info", severity, rule)
+		}
+		parsed[condition] = severity
+	}
+	return parsed, nil
+}
+
+func isSeverityCondition(condition string) bool {
+	if containsString(ERROR_CATEGORIES, condition) || condition == WARNING_KIND_SLOW || condition == WARNING_KIND_PERMANENT_REDIRECT || condition == WARNING_KIND_SIZE {
+		return true
+	}
+	if len(condition) == 3 && strings.HasSuffix(condition, "xx") {
+		return condition[0] >= '1' && condition[0] <= '5'
+	}
+	code, parseError := strconv.Atoi(condition)
+	return parseError == nil && code >= 100 && code <= 599
+}
+
+// Returns the severity of the result, empty for healthy links
+func (rules SeverityRules) Severity(result Result) string {
+	if result.IsWarning() {
+		if severity, found := rules[result.WarningKind]; found && result.WarningKind != "" {
+			return severity
+		}
+		return SEVERITY_WARNING
+	}
+	if result.Err == nil && result.IsHealthy() {
+		return ""
+	}
+
+	if result.Status != 0 {
+		status := strconv.Itoa(result.Status)
+		if severity, found := rules[status]; found {
+			return severity
+		}
+		if severity, found := rules[status[:1]+"xx"]; found {
+			return severity
+		}
+	}
+	if severity, found := rules[result.Category()]; found {
+		return severity
+	}
+	return SEVERITY_ERROR
+}
@@ -0,0 +1,76 @@
+package linkhealth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSeverityRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		want  map[string]string
+	}{
+		{"defaults", nil, map[string]string{"403": SEVERITY_WARNING, "429": SEVERITY_WARNING, WARNING_KIND_PERMANENT_REDIRECT: SEVERITY_INFO}},
+		{"status code", []string{"404=warning"}, map[string]string{"404": SEVERITY_WARNING}},
+		{"status class", []string{"5XX=info"}, map[string]string{"5xx": SEVERITY_INFO}},
+		{"error category", []string{"dns = Warning"}, map[string]string{ERROR_CATEGORY_DNS: SEVERITY_WARNING}},
+		{"warning kind", []string{"slow=error"}, map[string]string{WARNING_KIND_SLOW: SEVERITY_ERROR}},
+		{"overrides defaults", []string{"403=error"}, map[string]string{"403": SEVERITY_ERROR, "429": SEVERITY_WARNING}},
+		{"later rules win", []string{"404=warning", "404=info"}, map[string]string{"404": SEVERITY_INFO}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, parseError := ParseSeverityRules(test.rules)
+			if parseError != nil {
+				t.Fatalf("ParseSeverityRules(%q) failed: %s", test.rules, parseError)
+			}
+			for condition, want := range test.want {
+				if got := rules[condition]; got != want {
+					t.Errorf("ParseSeverityRules(%q)[%q] = %q, want %q", test.rules, condition, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSeverityRulesInvalid(t *testing.T) {
+	for _, rule := range []string{"404", "404=fatal", "6xx=warning", "0xx=warning", "99=warning", "600=warning", "unknown=error", "=error", "4x=warning"} {
+		if _, parseError := ParseSeverityRules([]string{rule}); parseError == nil {
+			t.Errorf("ParseSeverityRules(%q) succeeded, want an error", rule)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	rules, parseError := ParseSeverityRules([]string{"404=warning", "5xx=info", "503=error", "timeout=warning", "slow=error"})
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	timeout := withCategory(ERROR_CATEGORY_TIMEOUT, errors.New("Request timed out"))
+	tests := []struct {
+		name   string
+		result Result
+		want   string
+	}{
+		{"healthy", Result{Link: Link{Status: 200, Healthy: true}}, ""},
+		{"status code", Result{Link: Link{Status: 404}}, SEVERITY_WARNING},
+		{"default status code", Result{Link: Link{Status: 403}}, SEVERITY_WARNING},
+		{"status class", Result{Link: Link{Status: 500}}, SEVERITY_INFO},
+		{"status code before class", Result{Link: Link{Status: 503}}, SEVERITY_ERROR},
+		{"unmatched status", Result{Link: Link{Status: 410}}, SEVERITY_ERROR},
+		{"error category", Result{Err: timeout}, SEVERITY_WARNING},
+		{"healthy status with an error", Result{Link: Link{Status: 200, Healthy: true}, Err: withCategory(ERROR_CATEGORY_CONTENT, errors.New("Missing text"))}, SEVERITY_ERROR},
+		{"unmatched error", Result{Err: errors.New("Connection failed")}, SEVERITY_ERROR},
+		{"warning kind", Result{Warning: "Took 5s", WarningKind: WARNING_KIND_SLOW}, SEVERITY_ERROR},
+		{"default warning kind", Result{Warning: "Moved", WarningKind: WARNING_KIND_PERMANENT_REDIRECT}, SEVERITY_INFO},
+		{"other warning", Result{Warning: "Disallowed by robots.txt"}, SEVERITY_WARNING},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rules.Severity(test.result); got != test.want {
+				t.Errorf("Severity() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
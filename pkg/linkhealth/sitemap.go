@@ -0,0 +1,120 @@
+package linkhealth
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SITEMAP_PATH = "/sitemap.xml"
+	// Sitemaps are limited to 50MB uncompressed by the sitemap protocol
+	MAX_SITEMAP_SIZE = 50 * 1024 * 1024
+	// Number of nested sitemap index files followed
+	MAX_SITEMAP_INDEX_DEPTH = 3
+	SITEMAP_TIMEOUT         = 30 * time.Second
+)
+
+// Both sitemap files (urlset) and sitemap index files (sitemapindex) share this layout,
+// the former listing pages and the latter listing further sitemaps
+type sitemapDocument struct {
+	URLs []struct {
+		Location string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Location string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// Returns the default sitemap location of the site the URL belongs to
+func getSitemapURL(site *url.URL) string {
+	return (&url.URL{Scheme: site.Scheme, Host: site.Host, Path: SITEMAP_PATH}).String()
+}
+
+// Fetches sitemaps, following sitemap index files
+type sitemapLoader struct {
+	client  *http.Client
+	options Options
+	seen    map[string]bool
+	// Called for every page listed in a sitemap
+	found func(link string, sitemap string)
+	// Called for every sitemap that could not be loaded, status is 0 when no response was received
+	failed func(sitemap string, status int, err error)
+}
+
+// Loads the sitemap and any sitemaps it references
+func (loader *sitemapLoader) load(ctx context.Context, sitemap string, depth int) {
+	if loader.seen[sitemap] || ctx.Err() != nil {
+		return
+	}
+	loader.seen[sitemap] = true
+
+	document, status, loadError := loader.fetch(ctx, sitemap)
+	if loadError != nil {
+		loader.failed(sitemap, status, loadError)
+		return
+	}
+
+	for _, entry := range document.URLs {
+		if location := strings.TrimSpace(entry.Location); location != "" {
+			loader.found(location, sitemap)
+		}
+	}
+
+	for _, entry := range document.Sitemaps {
+		if depth >= MAX_SITEMAP_INDEX_DEPTH {
+			loader.failed(sitemap, status, fmt.Errorf("Sitemap index nested deeper than %d levels", MAX_SITEMAP_INDEX_DEPTH))
+			return
+		}
+		if location := strings.TrimSpace(entry.Location); location != "" {
+			loader.load(ctx, location, depth+1)
+		}
+	}
+}
+
+// Requests and parses a single sitemap, decompressing gzip sitemaps
+func (loader *sitemapLoader) fetch(ctx context.Context, sitemap string) (*sitemapDocument, int, error) {
+	request, requestError := http.NewRequest("GET", sitemap, nil)
+	if requestError != nil {
+		return nil, 0, requestError
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", loader.options.UserAgent)
+	applyHeaderRules(loader.options.HeaderRules, request.URL.Hostname(), request.Header)
+
+	response, responseError := loader.client.Do(request)
+	if responseError != nil {
+		return nil, 0, responseError
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < DEFAULT_HEALTHY_HTTP_MIN_STATUS_CODE || response.StatusCode > DEFAULT_HEALTHY_HTTP_MAX_STATUS_CODE {
+		return nil, response.StatusCode, fmt.Errorf("%s", http.StatusText(response.StatusCode))
+	}
+
+	// Compressed sitemaps are usually served as plain files rather than with a gzip Content-Encoding,
+	// so they are detected by their magic number
+	body := bufio.NewReader(io.LimitReader(response.Body, MAX_SITEMAP_SIZE))
+	var reader io.Reader = body
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, gzipError := gzip.NewReader(body)
+		if gzipError != nil {
+			return nil, response.StatusCode, gzipError
+		}
+		defer gzipReader.Close()
+		reader = io.LimitReader(gzipReader, MAX_SITEMAP_SIZE)
+	}
+
+	document := &sitemapDocument{}
+	if decodeError := xml.NewDecoder(reader).Decode(document); decodeError != nil {
+		return nil, response.StatusCode, fmt.Errorf("Invalid sitemap: %s", decodeError)
+	}
+	return document, response.StatusCode, nil
+}
@@ -0,0 +1,39 @@
+package linkhealth
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Number of latest stored runs the size of a link is compared with by default, see Store.LoadSizes
+const DEFAULT_SIZE_HISTORY_RUNS = 5
+
+// Returns why the size of a healthy, downloaded response is anomalous, or an empty string. Responses
+// smaller than Options.MinSize, or whose size differs from its size in previous runs by more than
+// Options.SizeDeviation percent, are often error pages or truncated deployments served with a healthy status.
+func checkSize(options Options, link Link) string {
+	if options.MinSize > 0 && link.Size < options.MinSize {
+		return fmt.Sprintf("%s is %d bytes, smaller than the minimum of %d bytes", link.URL, link.Size, options.MinSize)
+	}
+
+	previous, known := options.PreviousSizes[link.URL.String()]
+	if options.SizeDeviation <= 0 || !known || previous <= 0 {
+		return ""
+	}
+	deviation := float64(link.Size-previous) * 100 / float64(previous)
+	if deviation > float64(options.SizeDeviation) || -deviation > float64(options.SizeDeviation) {
+		return fmt.Sprintf("%s is %d bytes, %+.0f%% compared to %d bytes in previous runs", link.URL, link.Size, deviation, previous)
+	}
+	return ""
+}
+
+// Returns the median of the sizes
+func medianSize(sizes []int) int {
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
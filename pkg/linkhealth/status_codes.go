@@ -0,0 +1,64 @@
+package linkhealth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Status codes of healthy links when no other codes are configured
+const DEFAULT_HEALTHY_CODES = "200-299"
+
+// A set of HTTP status codes, made of single codes and inclusive ranges
+type StatusCodes []statusCodeRange
+
+type statusCodeRange struct {
+	min int
+	max int
+}
+
+// Parses a comma separated list of status codes and ranges, e.g. "200-299,301,302,429"
+func ParseStatusCodes(codes string) (StatusCodes, error) {
+	var parsed StatusCodes
+	for _, part := range strings.Split(codes, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		min, minError := parseStatusCode(bounds[0])
+		max := min
+		maxError := minError
+		if len(bounds) == 2 {
+			max, maxError = parseStatusCode(bounds[1])
+		}
+		if minError != nil || maxError != nil || min > max {
+			return nil, fmt.Errorf("Invalid status codes %q, expected codes or ranges like 200-299", part)
+		}
+
+		parsed = append(parsed, statusCodeRange{min: min, max: max})
+	}
+	return parsed, nil
+}
+
+func parseStatusCode(code string) (int, error) {
+	status, parseError := strconv.Atoi(strings.TrimSpace(code))
+	if parseError != nil {
+		return 0, parseError
+	}
+	if status < 100 || status > 599 {
+		return 0, fmt.Errorf("Status code %d out of range", status)
+	}
+	return status, nil
+}
+
+// Checks whether the status is in the set
+func (codes StatusCodes) Contains(status int) bool {
+	for _, codeRange := range codes {
+		if status >= codeRange.min && status <= codeRange.max {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package linkhealth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusCodes(t *testing.T) {
+	tests := []struct {
+		codes string
+		want  StatusCodes
+	}{
+		{"", nil},
+		{"200", StatusCodes{{200, 200}}},
+		{"200-299", StatusCodes{{200, 299}}},
+		{"200-299,301,302,429", StatusCodes{{200, 299}, {301, 301}, {302, 302}, {429, 429}}},
+		{" 200 - 204 , 404 ,", StatusCodes{{200, 204}, {404, 404}}},
+		{"100-599", StatusCodes{{100, 599}}},
+	}
+	for _, test := range tests {
+		t.Run(test.codes, func(t *testing.T) {
+			got, parseError := ParseStatusCodes(test.codes)
+			if parseError != nil {
+				t.Fatalf("ParseStatusCodes(%q) failed: %s", test.codes, parseError)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseStatusCodes(%q) = %v, want %v", test.codes, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusCodesInvalid(t *testing.T) {
+	for _, codes := range []string{"ok", "2xx", "99", "600", "299-200", "200-", "-299", "200-299-300", "200,abc"} {
+		if _, parseError := ParseStatusCodes(codes); parseError == nil {
+			t.Errorf("ParseStatusCodes(%q) succeeded, want an error", codes)
+		}
+	}
+}
+
+func TestStatusCodesContains(t *testing.T) {
+	codes, _ := ParseStatusCodes("200-299,301,429")
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{199, false},
+		{200, true},
+		{250, true},
+		{299, true},
+		{300, false},
+		{301, true},
+		{404, false},
+		{429, true},
+		{0, false},
+	}
+	for _, test := range tests {
+		if got := codes.Contains(test.status); got != test.want {
+			t.Errorf("Contains(%d) = %t, want %t", test.status, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package linkhealth
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// Bucket holding every recorded crawl, keyed by its sequential ID
+	STORE_RUNS_BUCKET = "runs"
+	// Bucket holding the validators of the links seen by the crawls, keyed by URL
+	STORE_VALIDATORS_BUCKET = "validators"
+	// Consecutive runs a link must be broken in to be reported as still broken by default
+	DEFAULT_BROKEN_RUNS = 3
+	STORE_OPEN_TIMEOUT  = 5 * time.Second
+)
+
+// Records the results of every crawl in a BoltDB file, so runs can be compared with each other
+type Store struct {
+	db *bolt.DB
+}
+
+// The results of a single crawl
+type StoredRun struct {
+	ID       uint64       `json:"id"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	Links    []StoredLink `json:"links"`
+}
+
+// The outcome of a checked link in a stored run
+type StoredLink struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	Healthy  bool   `json:"healthy"`
+	Error    string `json:"error,omitempty"`
+	Category string `json:"category,omitempty"`
+	// Bytes of the response body, zero when it was not downloaded
+	Size int `json:"size,omitempty"`
+}
+
+// Opens the store at the path, creating it when it does not exist
+func OpenStore(path string) (*Store, error) {
+	db, openError := bolt.Open(path, 0644, &bolt.Options{Timeout: STORE_OPEN_TIMEOUT})
+	if openError != nil {
+		return nil, fmt.Errorf("Could not open store %s: %s", path, openError)
+	}
+
+	updateError := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{STORE_RUNS_BUCKET, STORE_VALIDATORS_BUCKET} {
+			if _, bucketError := tx.CreateBucketIfNotExists([]byte(bucket)); bucketError != nil {
+				return bucketError
+			}
+		}
+		return nil
+	})
+	if updateError != nil {
+		db.Close()
+		return nil, updateError
+	}
+	return &Store{db: db}, nil
+}
+
+func (store *Store) Close() error {
+	return store.db.Close()
+}
+
+// Records a run, assigning it the next ID
+func (store *Store) SaveRun(run StoredRun) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(STORE_RUNS_BUCKET))
+		id, sequenceError := bucket.NextSequence()
+		if sequenceError != nil {
+			return sequenceError
+		}
+
+		run.ID = id
+		encoded, encodeError := json.Marshal(run)
+		if encodeError != nil {
+			return encodeError
+		}
+		return bucket.Put(runKey(id), encoded)
+	})
+}
+
+// Returns up to limit runs, latest first
+func (store *Store) LatestRuns(limit int) ([]StoredRun, error) {
+	var runs []StoredRun
+	viewError := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket([]byte(STORE_RUNS_BUCKET)).Cursor()
+		for key, value := cursor.Last(); key != nil && len(runs) < limit; key, value = cursor.Prev() {
+			var run StoredRun
+			if decodeError := json.Unmarshal(value, &run); decodeError != nil {
+				return fmt.Errorf("Invalid run %d in store: %s", binary.BigEndian.Uint64(key), decodeError)
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	return runs, viewError
+}
+
+// Returns the median size of every healthy link in the latest runs, the size anomalies of the next run are
+// detected against. A single unusual run thus does not skew the sizes.
+func (store *Store) LoadSizes(runs int) (map[string]int, error) {
+	latest, runsError := store.LatestRuns(runs)
+	if runsError != nil {
+		return nil, runsError
+	}
+
+	history := make(map[string][]int)
+	for _, run := range latest {
+		for _, link := range run.Links {
+			if link.Healthy && link.Size > 0 {
+				history[link.URL] = append(history[link.URL], link.Size)
+			}
+		}
+	}
+	sizes := make(map[string]int, len(history))
+	for link, linkSizes := range history {
+		sizes[link] = medianSize(linkSizes)
+	}
+	return sizes, nil
+}
+
+// Returns the validators recorded by previous runs, sent with conditional requests by the next one
+func (store *Store) LoadValidators() (*ResponseValidators, error) {
+	validators := NewResponseValidators()
+	viewError := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(STORE_VALIDATORS_BUCKET)).ForEach(func(key []byte, value []byte) error {
+			var validator Validator
+			if decodeError := json.Unmarshal(value, &validator); decodeError != nil {
+				return fmt.Errorf("Invalid validator of %s in store: %s", key, decodeError)
+			}
+			validators.Set(string(key), validator)
+			return nil
+		})
+	})
+	return validators, viewError
+}
+
+// Records the validators, replacing the ones previously recorded for the same URLs
+func (store *Store) SaveValidators(validators *ResponseValidators) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(STORE_VALIDATORS_BUCKET))
+		for link, validator := range validators.All() {
+			encoded, encodeError := json.Marshal(validator)
+			if encodeError != nil {
+				return encodeError
+			}
+			if putError := bucket.Put([]byte(link), encoded); putError != nil {
+				return putError
+			}
+		}
+		return nil
+	})
+}
+
+// Big endian keys keep runs in the order they were recorded
+func runKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// Converts a result into its stored form
+func NewStoredLink(result Result) StoredLink {
+	link := StoredLink{
+		URL:     result.URL.String(),
+		Status:  result.Status,
+		Healthy: result.Err == nil && result.IsHealthy(),
+	}
+	if result.Err != nil {
+		link.Error = result.Err.Error()
+	}
+	link.Category = result.Category()
+	if result.HasSize {
+		link.Size = result.Size
+	}
+	return link
+}
+
+// Changes between the latest run and the ones before it
+type RunDiff struct {
+	Latest   StoredRun
+	Previous StoredRun
+	// Broken in the latest run but not in the previous one
+	NewlyBroken []StoredLink
+	// Healthy in the latest run but broken in the previous one
+	NewlyHealthy []StoredLink
+	// Broken in each of the last BrokenRuns runs
+	StillBroken []StoredLink
+	BrokenRuns  int
+}
+
+// Compares the latest of the runs, given latest first, with the previous one. Links broken in each of the
+// last brokenRuns runs are reported as still broken, none are when there are fewer runs than that.
+func DiffRuns(runs []StoredRun, brokenRuns int) (RunDiff, error) {
+	if len(runs) < 2 {
+		return RunDiff{}, fmt.Errorf("At least two recorded runs are needed to compare, found %d", len(runs))
+	}
+
+	diff := RunDiff{Latest: runs[0], Previous: runs[1], BrokenRuns: brokenRuns}
+	previous := indexStoredLinks(runs[1])
+	for _, link := range runs[0].Links {
+		before, seen := previous[link.URL]
+		switch {
+		case !link.Healthy && (!seen || before.Healthy):
+			diff.NewlyBroken = append(diff.NewlyBroken, link)
+		case link.Healthy && seen && !before.Healthy:
+			diff.NewlyHealthy = append(diff.NewlyHealthy, link)
+		}
+	}
+
+	if brokenRuns > 0 && len(runs) >= brokenRuns {
+		older := make([]map[string]StoredLink, 0, brokenRuns-1)
+		for _, run := range runs[1:brokenRuns] {
+			older = append(older, indexStoredLinks(run))
+		}
+		for _, link := range runs[0].Links {
+			if !link.Healthy && isBrokenInEvery(link.URL, older) {
+				diff.StillBroken = append(diff.StillBroken, link)
+			}
+		}
+	}
+
+	for _, links := range [][]StoredLink{diff.NewlyBroken, diff.NewlyHealthy, diff.StillBroken} {
+		sortStoredLinks(links)
+	}
+	return diff, nil
+}
+
+func indexStoredLinks(run StoredRun) map[string]StoredLink {
+	index := make(map[string]StoredLink, len(run.Links))
+	for _, link := range run.Links {
+		index[link.URL] = link
+	}
+	return index
+}
+
+func isBrokenInEvery(link string, runs []map[string]StoredLink) bool {
+	for _, run := range runs {
+		if stored, seen := run[link]; !seen || stored.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func sortStoredLinks(links []StoredLink) {
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].URL < links[j].URL
+	})
+}
@@ -0,0 +1,129 @@
+package linkhealth
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Creates a stored run of the links, each given as its URL prefixed with + when healthy or - when broken
+func storedRun(id uint64, links ...string) StoredRun {
+	run := StoredRun{ID: id}
+	for _, link := range links {
+		stored := StoredLink{URL: link[1:], Healthy: link[0] == '+', Status: 200}
+		if !stored.Healthy {
+			stored.Status = 404
+		}
+		run.Links = append(run.Links, stored)
+	}
+	return run
+}
+
+func storedURLs(links []StoredLink) []string {
+	var urls []string
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+	return urls
+}
+
+func TestDiffRuns(t *testing.T) {
+	tests := []struct {
+		name       string
+		runs       []StoredRun
+		brokenRuns int
+		newly      []string
+		healthy    []string
+		still      []string
+	}{
+		{
+			name: "unchanged",
+			runs: []StoredRun{
+				storedRun(2, "+/a", "-/b"),
+				storedRun(1, "+/a", "-/b"),
+			},
+			brokenRuns: 2,
+			still:      []string{"/b"},
+		},
+		{
+			name: "newly broken and healthy",
+			runs: []StoredRun{
+				storedRun(2, "-/a", "+/b", "-/c"),
+				storedRun(1, "+/a", "-/b"),
+			},
+			newly:   []string{"/a", "/c"},
+			healthy: []string{"/b"},
+		},
+		{
+			name: "links only in the previous run are ignored",
+			runs: []StoredRun{
+				storedRun(2, "+/a"),
+				storedRun(1, "+/a", "-/b"),
+			},
+		},
+		{
+			name: "sorted by URL",
+			runs: []StoredRun{
+				storedRun(2, "-/c", "-/a", "-/b"),
+				storedRun(1),
+			},
+			newly: []string{"/a", "/b", "/c"},
+		},
+		{
+			name: "still broken in every run",
+			runs: []StoredRun{
+				storedRun(3, "-/a", "-/b", "-/c"),
+				storedRun(2, "-/a", "+/b", "-/c"),
+				storedRun(1, "-/a", "-/b"),
+			},
+			brokenRuns: 3,
+			newly:      []string{"/b"},
+			still:      []string{"/a"},
+		},
+		{
+			name: "fewer runs than brokenRuns",
+			runs: []StoredRun{
+				storedRun(2, "-/a"),
+				storedRun(1, "-/a"),
+			},
+			brokenRuns: 3,
+		},
+		{
+			name: "older runs beyond brokenRuns are ignored",
+			runs: []StoredRun{
+				storedRun(3, "-/a"),
+				storedRun(2, "-/a"),
+				storedRun(1, "+/a"),
+			},
+			brokenRuns: 2,
+			still:      []string{"/a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diff, diffError := DiffRuns(test.runs, test.brokenRuns)
+			if diffError != nil {
+				t.Fatalf("DiffRuns() failed: %s", diffError)
+			}
+			if diff.Latest.ID != test.runs[0].ID || diff.Previous.ID != test.runs[1].ID {
+				t.Errorf("DiffRuns() compared runs %d and %d, want %d and %d", diff.Latest.ID, diff.Previous.ID, test.runs[0].ID, test.runs[1].ID)
+			}
+			if got := storedURLs(diff.NewlyBroken); !reflect.DeepEqual(got, test.newly) {
+				t.Errorf("NewlyBroken = %q, want %q", got, test.newly)
+			}
+			if got := storedURLs(diff.NewlyHealthy); !reflect.DeepEqual(got, test.healthy) {
+				t.Errorf("NewlyHealthy = %q, want %q", got, test.healthy)
+			}
+			if got := storedURLs(diff.StillBroken); !reflect.DeepEqual(got, test.still) {
+				t.Errorf("StillBroken = %q, want %q", got, test.still)
+			}
+		})
+	}
+}
+
+func TestDiffRunsNeedsTwoRuns(t *testing.T) {
+	for _, runs := range [][]StoredRun{nil, {storedRun(1, "-/a")}} {
+		if _, diffError := DiffRuns(runs, 2); diffError == nil {
+			t.Errorf("DiffRuns() of %d runs succeeded, want an error", len(runs))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package linkhealth
+
+import (
+	"sort"
+	"time"
+)
+
+// Number of slowest links kept by a summary
+const SUMMARY_SLOWEST_LINKS = 5
+
+// Statistics about the results of a crawl
+type Summary struct {
+	Checked  int
+	Healthy  int
+	Broken   int
+	Warnings int
+	// Healthy links slower than the slow threshold
+	Slow int
+	// Number of checked links per response status code, requests that failed without a response use 0
+	StatusCodes map[int]int
+	// Number of broken links per error category, see CategorizeError
+	Categories map[string]int
+	// Days until the TLS certificate of each HTTPS host expires
+	Certificates map[string]int
+	// Links with the highest latency, slowest first
+	Slowest  []Link
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Creates an empty summary, starting the crawl duration
+func NewSummary() *Summary {
+	return &Summary{
+		StatusCodes:  make(map[int]int),
+		Categories:   make(map[string]int),
+		Certificates: make(map[string]int),
+		Started:      time.Now(),
+	}
+}
+
+// Adds a result to the statistics
+func (summary *Summary) Add(result Result) {
+	if result.IsWarning() {
+		summary.Warnings++
+		return
+	}
+
+	summary.Checked++
+	summary.StatusCodes[result.Status]++
+	if result.HasCertificate {
+		summary.Certificates[result.URL.Hostname()] = result.CertExpiryDays
+	}
+	if result.Err == nil && result.IsHealthy() {
+		summary.Healthy++
+		if result.Slow {
+			summary.Slow++
+		}
+	} else {
+		summary.Broken++
+		summary.Categories[result.Category()]++
+	}
+
+	summary.addSlowest(result.Link)
+}
+
+// Keeps the link when it is among the slowest links seen so far
+func (summary *Summary) addSlowest(link Link) {
+	if len(summary.Slowest) == SUMMARY_SLOWEST_LINKS && link.Latency <= summary.Slowest[len(summary.Slowest)-1].Latency {
+		return
+	}
+
+	summary.Slowest = append(summary.Slowest, link)
+	sort.SliceStable(summary.Slowest, func(i, j int) bool {
+		return summary.Slowest[i].Latency > summary.Slowest[j].Latency
+	})
+	if len(summary.Slowest) > SUMMARY_SLOWEST_LINKS {
+		summary.Slowest = summary.Slowest[:SUMMARY_SLOWEST_LINKS]
+	}
+}
+
+// Stops the crawl duration
+func (summary *Summary) Finish() {
+	summary.Duration = time.Since(summary.Started)
+}
+
+// Returns the HTTPS hosts seen, soonest certificate expiry first
+func (summary *Summary) SortedCertificateHosts() []string {
+	hosts := make([]string, 0, len(summary.Certificates))
+	for host := range summary.Certificates {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if summary.Certificates[hosts[i]] != summary.Certificates[hosts[j]] {
+			return summary.Certificates[hosts[i]] < summary.Certificates[hosts[j]]
+		}
+		return hosts[i] < hosts[j]
+	})
+	return hosts
+}
+
+// Returns the error categories of the broken links, most frequent first
+func (summary *Summary) SortedCategories() []string {
+	categories := make([]string, 0, len(summary.Categories))
+	for category := range summary.Categories {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if summary.Categories[categories[i]] != summary.Categories[categories[j]] {
+			return summary.Categories[categories[i]] > summary.Categories[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// Returns the status codes seen, in ascending order
+func (summary *Summary) SortedStatusCodes() []int {
+	codes := make([]int, 0, len(summary.StatusCodes))
+	for code := range summary.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
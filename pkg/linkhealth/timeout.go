@@ -0,0 +1,42 @@
+package linkhealth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Matches the default timeout of colly collectors
+const DEFAULT_REQUEST_TIMEOUT = 10 * time.Second
+
+// Bounds each request made through the wrapped transport, from sending the request until its body is closed.
+// Unlike a client timeout the bound applies to every attempt separately, so timed out requests can be retried.
+type timeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (timeout *timeoutTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(request.Context(), timeout.timeout)
+	response, roundTripError := timeout.transport.RoundTrip(request.WithContext(ctx))
+	if roundTripError != nil {
+		cancel()
+		return nil, roundTripError
+	}
+
+	response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+// A response body releasing its request context once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	closeError := body.ReadCloser.Close()
+	body.cancel()
+	return closeError
+}
@@ -0,0 +1,105 @@
+package linkhealth
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Measures how long each request made through the wrapped transport takes, from sending the
+// request until the response headers arrive and until its body is fully read. Timings are keyed by the URL the
+// redirects started from, adding up the time of every hop.
+type timingTransport struct {
+	transport http.RoundTripper
+	lock      sync.Mutex
+	timings   map[string]requestTiming
+}
+
+type requestTiming struct {
+	firstByte time.Duration
+	total     time.Duration
+}
+
+func newTimingTransport(transport http.RoundTripper) *timingTransport {
+	return &timingTransport{
+		transport: transport,
+		timings:   make(map[string]requestTiming),
+	}
+}
+
+func (timing *timingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// The http client sets the response that led to each redirected request
+	original := request
+	for original.Response != nil && original.Response.Request != nil {
+		original = original.Response.Request
+	}
+	link := original.URL.String()
+	if original == request {
+		// Retries measure the link again
+		timing.reset(link)
+	}
+
+	start := time.Now()
+	response, roundTripError := timing.transport.RoundTrip(request)
+	if roundTripError != nil {
+		elapsed := time.Since(start)
+		timing.record(link, requestTiming{firstByte: elapsed, total: elapsed})
+		return nil, roundTripError
+	}
+
+	firstByte := time.Since(start)
+	response.Body = &timedBody{
+		ReadCloser: response.Body,
+		done: func() {
+			timing.record(link, requestTiming{firstByte: firstByte, total: time.Since(start)})
+		},
+	}
+	return response, nil
+}
+
+func (timing *timingTransport) reset(link string) {
+	timing.lock.Lock()
+	delete(timing.timings, link)
+	timing.lock.Unlock()
+}
+
+// Adds a hop to the link's timing, the redirects before it count towards the time to first byte
+func (timing *timingTransport) record(link string, recorded requestTiming) {
+	timing.lock.Lock()
+	previous := timing.timings[link]
+	timing.timings[link] = requestTiming{
+		firstByte: previous.total + recorded.firstByte,
+		total:     previous.total + recorded.total,
+	}
+	timing.lock.Unlock()
+}
+
+// Returns and forgets the total latency and time to first byte recorded for the URL the redirects started from
+func (timing *timingTransport) latency(link string) (time.Duration, time.Duration) {
+	timing.lock.Lock()
+	defer timing.lock.Unlock()
+	recorded := timing.timings[link]
+	delete(timing.timings, link)
+	return recorded.total, recorded.firstByte
+}
+
+// A response body calling done once it has been read to the end or closed
+type timedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (body *timedBody) Read(buffer []byte) (int, error) {
+	read, readError := body.ReadCloser.Read(buffer)
+	if readError != nil {
+		body.once.Do(body.done)
+	}
+	return read, readError
+}
+
+func (body *timedBody) Close() error {
+	body.once.Do(body.done)
+	return body.ReadCloser.Close()
+}
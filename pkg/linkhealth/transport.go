@@ -0,0 +1,66 @@
+package linkhealth
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// IP versions connections can prefer, see Options.PreferIPVersion
+const (
+	IP_VERSION_4 = "4"
+	IP_VERSION_6 = "6"
+)
+
+// Match the defaults of the standard library transport
+const (
+	DEFAULT_IDLE_CONN_TIMEOUT = 90 * time.Second
+	DIAL_TIMEOUT              = 30 * time.Second
+	DIAL_KEEP_ALIVE           = 30 * time.Second
+)
+
+// Applies the connection settings of the options to the transport. Idle connections are kept for every
+// worker by default, since the standard library only keeps two per host, making large crawls of a single
+// origin open a new connection for most requests.
+func tuneTransport(transport *http.Transport, options Options) {
+	transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	if transport.MaxIdleConnsPerHost <= 0 {
+		transport.MaxIdleConnsPerHost = options.Threads + options.ExternalThreads
+	}
+	if transport.MaxIdleConnsPerHost > transport.MaxIdleConns {
+		transport.MaxIdleConns = transport.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = options.IdleConnTimeout
+	if transport.IdleConnTimeout <= 0 {
+		transport.IdleConnTimeout = DEFAULT_IDLE_CONN_TIMEOUT
+	}
+	transport.DisableKeepAlives = options.DisableKeepAlives
+
+	if options.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// A non-nil map keeps the transport from upgrading connections to HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	dialer := &net.Dialer{Timeout: DIAL_TIMEOUT, KeepAlive: DIAL_KEEP_ALIVE}
+	transport.DialContext = dialer.DialContext
+	if options.PreferIPVersion != "" {
+		transport.DialContext = preferIPVersion(dialer, options.PreferIPVersion)
+	}
+}
+
+// Dials the addresses of the preferred IP version first, falling back to any address when the host has none
+// or they cannot be connected to
+func preferIPVersion(dialer *net.Dialer, version string) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if network == "tcp" {
+			connection, dialError := dialer.DialContext(ctx, network+version, address)
+			if dialError == nil || ctx.Err() != nil {
+				return connection, dialError
+			}
+		}
+		return dialer.DialContext(ctx, network, address)
+	}
+}
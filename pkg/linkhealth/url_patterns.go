@@ -0,0 +1,76 @@
+package linkhealth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix marking a URL pattern as a glob rather than a regular expression
+const GLOB_PATTERN_PREFIX = "glob:"
+
+// Compiles a URL pattern matched against the absolute URL. Patterns are regular expressions matching
+// anywhere in the URL, or globs matching the whole URL when prefixed with "glob:", e.g. glob:*/logout*.
+// In globs * matches any characters, including slashes, and ? matches a single character.
+func CompileURLPattern(pattern string) (*regexp.Regexp, error) {
+	expression := pattern
+	if strings.HasPrefix(pattern, GLOB_PATTERN_PREFIX) {
+		expression = globToRegexp(strings.TrimPrefix(pattern, GLOB_PATTERN_PREFIX))
+	}
+
+	compiled, compileError := regexp.Compile(expression)
+	if compileError != nil {
+		return nil, fmt.Errorf("Invalid URL pattern %q: %s", pattern, compileError)
+	}
+	return compiled, nil
+}
+
+// Compiles every URL pattern, see CompileURLPattern
+func CompileURLPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		urlPattern, compileError := CompileURLPattern(pattern)
+		if compileError != nil {
+			return nil, compileError
+		}
+		compiled = append(compiled, urlPattern)
+	}
+	return compiled, nil
+}
+
+func globToRegexp(glob string) string {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for _, character := range glob {
+		switch character {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+	expression.WriteString("$")
+	return expression.String()
+}
+
+// Checks whether a discovered link should be visited: it must not match any exclude pattern,
+// and must match an include pattern when there are any
+func isIncluded(options Options, link string) bool {
+	for _, pattern := range options.Exclude {
+		if pattern.MatchString(link) {
+			return false
+		}
+	}
+
+	if len(options.Include) == 0 {
+		return true
+	}
+	for _, pattern := range options.Include {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
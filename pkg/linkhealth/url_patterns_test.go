@@ -0,0 +1,60 @@
+package linkhealth
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"", "^$"},
+		{"https://site.com/", `^https://site\.com/$`},
+		{"*/logout*", "^.*/logout.*$"},
+		{"https://site.com/page?.html", `^https://site\.com/page.\.html$`},
+		{"https://site.com/(a)+[b]", `^https://site\.com/\(a\)\+\[b\]$`},
+	}
+	for _, test := range tests {
+		t.Run(test.glob, func(t *testing.T) {
+			if got := globToRegexp(test.glob); got != test.want {
+				t.Errorf("globToRegexp(%q) = %q, want %q", test.glob, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompileURLPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		link    string
+		matches bool
+	}{
+		{"regexp matches anywhere", "/logout", "https://site.com/account/logout?next=/", true},
+		{"regexp anchored", "^https://site\\.com/docs/", "https://site.com/docs/intro", true},
+		{"regexp anchored mismatch", "^https://site\\.com/docs/", "https://site.com/blog/docs/", false},
+		{"glob star crosses slashes", "glob:*/logout*", "https://site.com/account/logout?next=/", true},
+		{"glob matches the whole URL", "glob:/logout", "https://site.com/logout", false},
+		{"glob question mark", "glob:https://site.com/page?.html", "https://site.com/page2.html", true},
+		{"glob question mark single character", "glob:https://site.com/page?.html", "https://site.com/page10.html", false},
+		{"glob dot is literal", "glob:https://site.com/*", "https://siteXcom/page", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pattern, compileError := CompileURLPattern(test.pattern)
+			if compileError != nil {
+				t.Fatalf("CompileURLPattern(%q) failed: %s", test.pattern, compileError)
+			}
+			if got := pattern.MatchString(test.link); got != test.matches {
+				t.Errorf("CompileURLPattern(%q).MatchString(%q) = %t, want %t", test.pattern, test.link, got, test.matches)
+			}
+		})
+	}
+}
+
+func TestCompileURLPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"(", "[a-", "a**"} {
+		if _, compileError := CompileURLPattern(pattern); compileError == nil {
+			t.Errorf("CompileURLPattern(%q) succeeded, want an error", pattern)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package linkhealth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Availability API of the Internet Archive, returning the closest archived snapshot of a URL
+const DEFAULT_WAYBACK_API = "https://archive.org/wayback/available"
+
+// Largest availability response read, responses are a few hundred bytes
+const MAX_WAYBACK_RESPONSE_SIZE = 64 * 1024
+
+// Statuses of links whose page is gone, for which an archived snapshot is looked up
+var WAYBACK_STATUS_CODES = []int{http.StatusNotFound, http.StatusGone}
+
+type waybackResponse struct {
+	ArchivedSnapshots struct {
+		Closest *struct {
+			Available bool   `json:"available"`
+			URL       string `json:"url"`
+		} `json:"closest"`
+	} `json:"archived_snapshots"`
+}
+
+// Looks up archived snapshots of dead links, so broken links come with a candidate replacement.
+// Every link is looked up once, however often it is reported.
+type waybackClient struct {
+	api       string
+	userAgent string
+	client    *http.Client
+	lock      sync.Mutex
+	snapshots map[string]*waybackSnapshot
+}
+
+type waybackSnapshot struct {
+	once sync.Once
+	url  string
+	err  error
+}
+
+func newWaybackClient(options Options) *waybackClient {
+	return &waybackClient{
+		api:       options.WaybackAPI,
+		userAgent: options.UserAgent,
+		client: &http.Client{
+			Transport: &timeoutTransport{transport: getTransport(newCertificateTracker(), options), timeout: options.Timeout},
+		},
+		snapshots: make(map[string]*waybackSnapshot),
+	}
+}
+
+// Whether the link is gone rather than temporarily failing, by its status or because its host does not resolve
+func isDeadLink(status int, err error) bool {
+	for _, code := range WAYBACK_STATUS_CODES {
+		if status == code {
+			return true
+		}
+	}
+	return status == 0 && CategorizeError(err, status) == ERROR_CATEGORY_DNS
+}
+
+// Returns the URL of the closest archived snapshot of the link, empty when the link was never archived
+func (wayback *waybackClient) snapshot(ctx context.Context, link string) (string, error) {
+	wayback.lock.Lock()
+	snapshot, ok := wayback.snapshots[link]
+	if !ok {
+		snapshot = &waybackSnapshot{}
+		wayback.snapshots[link] = snapshot
+	}
+	wayback.lock.Unlock()
+
+	snapshot.once.Do(func() {
+		snapshot.url, snapshot.err = wayback.lookup(ctx, link)
+	})
+	return snapshot.url, snapshot.err
+}
+
+func (wayback *waybackClient) lookup(ctx context.Context, link string) (string, error) {
+	request, requestError := http.NewRequest("GET", wayback.api+"?url="+url.QueryEscape(link), nil)
+	if requestError != nil {
+		return "", requestError
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("User-Agent", wayback.userAgent)
+
+	response, responseError := wayback.client.Do(request)
+	if responseError != nil {
+		return "", responseError
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+
+	var availability waybackResponse
+	if decodeError := json.NewDecoder(io.LimitReader(response.Body, MAX_WAYBACK_RESPONSE_SIZE)).Decode(&availability); decodeError != nil {
+		return "", fmt.Errorf("Invalid response: %s", decodeError)
+	}
+	closest := availability.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available {
+		return "", nil
+	}
+	return closest.URL, nil
+}